@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxStatsDays caps how many days of statistics one request covers.
+const maxStatsDays = 366
+
+var (
+	errNothingToDelete = errors.New("name at least one post or comment to delete")
+	errStatsRange      = errors.New("statistics cover at most 366 days at a time")
+)
+
+// adminUserContextMiddleware loads the user named in the URL for admins,
+// who see suspended and deactivated users too.
+func (app *application) adminUserContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			app.statusBadRequest(w, r, err)
+			return
+		}
+		user, err := app.store.Users.GetByID(r.Context(), userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		ctx := context.WithValue(r.Context(), UserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SearchUsers godoc
+//
+//	@Summary		Searches users
+//	@Description	Lists users whose username or email address contains q, oldest first, with their email addresses, roles, verification and deactivation. Only admins may use it.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			q		query		string	false	"Part of a username or email address"
+//	@Param			role	query		string	false	"Only users with this role: user, moderator or admin"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users [get]
+func (app *application) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	uq := store.AdminUserQuery{
+		Limit: 20,
+	}
+	uq, err := uq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(uq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	users, err := app.store.Users.Search(r.Context(), uq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAdminUser godoc
+//
+//	@Summary		Fetches a user
+//	@Description	Fetches a user with their email address, role, verification and deactivation, even while they are suspended or deactivated
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID} [get]
+func (app *application) getAdminUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getUserFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// parseAdminList reads the page of an admin listing from the query string.
+func parseAdminList(r *http.Request) (store.AdminListQuery, error) {
+	aq := store.AdminListQuery{
+		Limit: 20,
+	}
+	aq, err := aq.Parse(r)
+	if err != nil {
+		return aq, err
+	}
+	return aq, Validator.Struct(aq)
+}
+
+// GetAdminUserPosts godoc
+//
+//	@Summary		Lists a user's posts
+//	@Description	Lists every post a user wrote, newest first, including drafts and hidden and deleted posts
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.AdminPost
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/posts [get]
+func (app *application) getAdminUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	aq, err := parseAdminList(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	posts, err := app.store.Admin.GetUserPosts(r.Context(), getUserFromCtx(r).ID, aq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAdminUserComments godoc
+//
+//	@Summary		Lists a user's comments
+//	@Description	Lists every comment a user wrote, newest first, including hidden comments
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.AdminComment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/comments [get]
+func (app *application) getAdminUserCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	aq, err := parseAdminList(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	comments, err := app.store.Admin.GetUserComments(r.Context(), getUserFromCtx(r).ID, aq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, comments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAdminUserFollowers godoc
+//
+//	@Summary		Lists a user's followers
+//	@Description	Lists the users following a user, most recent first, whatever the user's privacy and blocks
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.AdminFollow
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/followers [get]
+func (app *application) getAdminUserFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	aq, err := parseAdminList(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	followers, err := app.store.Admin.GetFollowers(r.Context(), getUserFromCtx(r).ID, aq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, followers); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAdminUserFollowing godoc
+//
+//	@Summary		Lists who a user follows
+//	@Description	Lists the users a user follows, most recent first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.AdminFollow
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/following [get]
+func (app *application) getAdminUserFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	aq, err := parseAdminList(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	following, err := app.store.Admin.GetFollowing(r.Context(), getUserFromCtx(r).ID, aq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, following); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// VerifyUser godoc
+//
+//	@Summary		Verifies a user
+//	@Description	Marks a user as verified, which their profile shows
+//	@Tags			admin
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User verified"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/verification [put]
+func (app *application) verifyUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUser(w, r, func(ctx context.Context, userID int64) error {
+		return app.store.Users.SetVerified(ctx, userID, true)
+	})
+}
+
+// UnverifyUser godoc
+//
+//	@Summary		Unverifies a user
+//	@Description	Takes a user's verification away
+//	@Tags			admin
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Verification removed"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/verification [delete]
+func (app *application) unverifyUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUser(w, r, func(ctx context.Context, userID int64) error {
+		return app.store.Users.SetVerified(ctx, userID, false)
+	})
+}
+
+// DeactivateUser godoc
+//
+//	@Summary		Deactivates a user
+//	@Description	Deactivates a user's account until it is reactivated. Deactivated users cannot use the API, their profiles are gone and their posts are left out of feeds. Admins cannot deactivate themselves.
+//	@Tags			admin
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User deactivated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/deactivation [put]
+func (app *application) deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	if getUserFromCtx(r).ID == getViewerID(r) {
+		app.statusBadRequest(w, r, errSelfTarget)
+		return
+	}
+	app.updateUser(w, r, func(ctx context.Context, userID int64) error {
+		return app.store.Users.SetActive(ctx, userID, false)
+	})
+}
+
+// ReactivateUser godoc
+//
+//	@Summary		Reactivates a user
+//	@Description	Reactivates a deactivated account
+//	@Tags			admin
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User reactivated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/deactivation [delete]
+func (app *application) reactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateUser(w, r, func(ctx context.Context, userID int64) error {
+		return app.store.Users.SetActive(ctx, userID, true)
+	})
+}
+
+// updateUser applies update to the user in the request context and answers
+// 204.
+func (app *application) updateUser(w http.ResponseWriter, r *http.Request, update func(context.Context, int64) error) {
+	if err := update(r.Context(), getUserFromCtx(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type DeleteContentPayload struct {
+	PostIDs    []int64 `json:"post_ids" validate:"max=100,dive,gte=1"`
+	CommentIDs []int64 `json:"comment_ids" validate:"max=100,dive,gte=1"`
+}
+
+// DeleteContent godoc
+//
+//	@Summary		Deletes content in bulk
+//	@Description	Deletes up to 100 posts and 100 comments at once. Posts go to their authors' trash hidden, so restoring them does not make them visible again; comments are deleted for good. Each deletion is audited. The response lists what was deleted, leaving out anything that did not exist or was already deleted.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DeleteContentPayload	true	"Posts and comments to delete"
+//	@Success		200		{object}	store.DeletedContent
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/content/delete [post]
+func (app *application) deleteContentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DeleteContentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if len(payload.PostIDs) == 0 && len(payload.CommentIDs) == 0 {
+		app.statusBadRequest(w, r, errNothingToDelete)
+		return
+	}
+
+	deleted, err := app.store.Admin.DeleteContent(r.Context(), payload.PostIDs, payload.CommentIDs)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, deleted); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetStats godoc
+//
+//	@Summary		Fetches daily statistics
+//	@Description	Counts the users, posts and comments created each day, in UTC, over up to 366 days. It covers the last 30 days by default.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			from	query		string	false	"First day, as YYYY-MM-DD"
+//	@Param			to		query		string	false	"Last day, as YYYY-MM-DD"
+//	@Success		200		{object}	[]store.DailyStats
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/stats [get]
+func (app *application) getStatsHandler(w http.ResponseWriter, r *http.Request) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	sq := store.StatsQuery{
+		From: today.AddDate(0, 0, -29),
+		To:   today,
+	}
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(sq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if sq.To.Sub(sq.From) >= maxStatsDays*24*time.Hour {
+		app.statusBadRequest(w, r, errStatsRange)
+		return
+	}
+
+	stats, err := app.store.Admin.GetDailyStats(r.Context(), sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, stats); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type SetRolePayload struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+// SetUserRole godoc
+//
+//	@Summary		Changes a user's role
+//	@Description	Makes a user a regular user, a moderator or an admin. Admins cannot change their own role.
+//	@Tags			admin
+//	@Accept			json
+//	@Param			userID	path		int				true	"User ID"
+//	@Param			payload	body		SetRolePayload	true	"Role"
+//	@Success		204		{string}	string			"Role changed"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/role [put]
+func (app *application) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload SetRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if userID == getViewerID(r) {
+		app.statusBadRequest(w, r, errSelfTarget)
+		return
+	}
+
+	if err := app.store.Users.SetRole(r.Context(), userID, payload.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
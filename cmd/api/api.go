@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/Chandan185/Societal/docs"
+	"github.com/Chandan185/Societal/internal/blob"
+	"github.com/Chandan185/Societal/internal/events"
+	"github.com/Chandan185/Societal/internal/jobs"
+	"github.com/Chandan185/Societal/internal/media"
+	"github.com/Chandan185/Societal/internal/moderation"
+	"github.com/Chandan185/Societal/internal/pubsub"
 	"github.com/Chandan185/Societal/internal/store"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,16 +23,78 @@ import (
 )
 
 type application struct {
-	config config
-	store  store.Storage
-	logger *zap.SugaredLogger
+	config         config
+	store          store.Storage
+	blobs          blob.BlobStore
+	mediaProcessor *media.Processor
+	broker         pubsub.Broker
+	jobs           *jobs.Pool
+	events         *events.Relay
+	contentFilter  *moderation.Filter
+	logger         *zap.SugaredLogger
 }
 
 type config struct {
-	addr   string
-	db     dbConfig
-	env    string
-	apiURL string
+	addr       string
+	db         dbConfig
+	env        string
+	apiURL     string
+	media      mediaConfig
+	stream     streamConfig
+	trash      trashConfig
+	jobs       jobsConfig
+	events     eventsConfig
+	webhooks   webhooksConfig
+	moderation moderationConfig
+	// scheduleInterval is how often scheduled posts are checked for ones
+	// that are due.
+	scheduleInterval time.Duration
+}
+
+type streamConfig struct {
+	broker string
+}
+
+type trashConfig struct {
+	// retention is how long deleted posts can be restored before they are
+	// purged.
+	retention     time.Duration
+	purgeInterval time.Duration
+}
+
+type jobsConfig struct {
+	concurrency int
+	// timeout bounds a single attempt at a background job.
+	timeout time.Duration
+}
+
+type eventsConfig struct {
+	pollInterval time.Duration
+	// retention is how long delivered events stay in the outbox.
+	retention time.Duration
+}
+
+type webhooksConfig struct {
+	// timeout bounds a single delivery request.
+	timeout time.Duration
+	// disableAfter is how many failed deliveries in a row disable a
+	// webhook.
+	disableAfter int
+}
+
+type moderationConfig struct {
+	// reportHideThreshold is how many open reports hide a post or comment
+	// until a moderator reviews them. Zero never hides anything.
+	reportHideThreshold int
+	// rulesFile is the JSON config of the content filter. The default
+	// filter is used when it is empty.
+	rulesFile string
+}
+
+type mediaConfig struct {
+	dir            string
+	maxUploadBytes int64
+	workers        int
 }
 
 type dbConfig struct {
@@ -38,56 +109,204 @@ func (app *application) mount() http.Handler {
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	r.Use(app.auditMiddleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	// Set a timeout value on the request context (ctx), that will signal
-	// through ctx.Done() that the request has timed out and further
-	// processing should be stopped.
-	r.Use(middleware.Timeout(60 * time.Second))
-
 	r.Route("/v1", func(r chi.Router) {
-		r.Get("/health", app.healthCheckHandler)
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
-		r.Get("/swagger/*", httpSwagger.Handler((httpSwagger.URL(docsURL))))
-		r.Route("/posts", func(r chi.Router) {
-			r.Post("/", app.createPostHandler)
-
-			r.Route("/{postID}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
-				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.deletePostHandler)
-				r.Patch("/", app.updatePostHandler)
-			})
-		})
-		r.Route("/users", func(r chi.Router) {
-			r.Route("/{userID}", func(r chi.Router) {
-				r.Use(app.userContextMiddleware)
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Delete("/unfollow", app.unfollowUserHandler)
-			})
+		// Streams and sockets stay open for as long as the client is
+		// connected, so they are mounted outside the request timeout.
+		r.With(app.activeViewerMiddleware).Get("/stream", app.streamHandler)
+		r.With(app.activeViewerMiddleware).Get("/conversations/ws", app.messagesSocketHandler)
+
+		r.Group(func(r chi.Router) {
+			// Set a timeout value on the request context (ctx), that will signal
+			// through ctx.Done() that the request has timed out and further
+			// processing should be stopped.
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			r.Get("/health", app.healthCheckHandler)
+			docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
+			r.Get("/swagger/*", httpSwagger.Handler((httpSwagger.URL(docsURL))))
+
+			// Suspended users cannot use the API.
 			r.Group(func(r chi.Router) {
-				r.Get("/feed", app.getUserFeedHandler)
+				r.Use(app.activeViewerMiddleware)
+				r.Route("/posts", func(r chi.Router) {
+					r.Post("/", app.createPostHandler)
+					// Deleted posts are not found by postsContextMiddleware.
+					r.Post("/{postID}/restore", app.restorePostHandler)
+
+					r.Route("/{postID}", func(r chi.Router) {
+						r.Use(app.postsContextMiddleware)
+						r.Get("/", app.getPostHandler)
+						r.Delete("/", app.deletePostHandler)
+						r.Patch("/", app.updatePostHandler)
+						r.Post("/comments", app.createCommentHandler)
+						r.Put("/bookmark", app.bookmarkPostHandler)
+						r.Delete("/bookmark", app.unbookmarkPostHandler)
+						r.Put("/repost", app.repostHandler)
+						r.Delete("/repost", app.unrepostHandler)
+						r.Get("/revisions", app.getPostRevisionsHandler)
+						r.Get("/revisions/diff", app.diffPostRevisionsHandler)
+					})
+				})
+				r.Route("/media", func(r chi.Router) {
+					r.Post("/", app.uploadMediaHandler)
+					r.Get("/{mediaID}", app.getMediaHandler)
+				})
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Get("/", app.getWebhooksHandler)
+					r.Post("/", app.createWebhookHandler)
+					r.Route("/{webhookID}", func(r chi.Router) {
+						r.Get("/", app.getWebhookHandler)
+						r.Patch("/", app.updateWebhookHandler)
+						r.Delete("/", app.deleteWebhookHandler)
+						r.Get("/deliveries", app.getWebhookDeliveriesHandler)
+						r.Post("/deliveries/{deliveryID}/redeliver", app.redeliverWebhookHandler)
+					})
+				})
+				r.Post("/reports", app.createReportHandler)
+				r.Route("/moderation", func(r chi.Router) {
+					r.Use(app.requireRole(store.RoleModerator, store.RoleAdmin))
+					r.Get("/reports", app.getReportQueueHandler)
+					r.Route("/reports/{reportID}", func(r chi.Router) {
+						r.Get("/", app.getReportHandler)
+						r.Put("/assignee", app.assignReportHandler)
+						r.Post("/actions", app.actOnReportHandler)
+					})
+				})
+				r.Route("/notifications", func(r chi.Router) {
+					r.Get("/", app.getNotificationsHandler)
+					r.Post("/read", app.markNotificationsReadHandler)
+					r.Get("/unread-count", app.getUnreadNotificationCountHandler)
+				})
+				r.Route("/conversations", func(r chi.Router) {
+					r.Get("/", app.getConversationsHandler)
+					r.Get("/{conversationID}/messages", app.getMessagesHandler)
+					r.Post("/{conversationID}/read", app.markConversationReadHandler)
+				})
+				r.Route("/users", func(r chi.Router) {
+					r.Route("/me", func(r chi.Router) {
+						r.Get("/", app.getMeHandler)
+						r.Patch("/", app.updateProfileHandler)
+						r.Put("/privacy", app.updatePrivacyHandler)
+						r.Get("/follow-requests", app.getFollowRequestsHandler)
+						r.Put("/follow-requests/{requesterID}", app.approveFollowRequestHandler)
+						r.Delete("/follow-requests/{requesterID}", app.rejectFollowRequestHandler)
+						r.Get("/bookmarks", app.getBookmarksHandler)
+						r.Get("/trash", app.getTrashHandler)
+						r.Get("/drafts", app.getDraftsHandler)
+						r.Get("/collections", app.getCollectionsHandler)
+						r.Post("/collections", app.createCollectionHandler)
+						r.Patch("/collections/{collectionID}", app.renameCollectionHandler)
+						r.Delete("/collections/{collectionID}", app.deleteCollectionHandler)
+					})
+					r.Route("/{userID}", func(r chi.Router) {
+						r.Use(app.userContextMiddleware)
+						r.Get("/", app.getUserHandler)
+						r.Put("/follow", app.followUserHandler)
+						r.Delete("/unfollow", app.unfollowUserHandler)
+						r.Put("/block", app.blockUserHandler)
+						r.Delete("/block", app.unblockUserHandler)
+						r.Put("/mute", app.muteUserHandler)
+						r.Delete("/mute", app.unmuteUserHandler)
+					})
+					r.Group(func(r chi.Router) {
+						r.Get("/feed", app.getUserFeedHandler)
+					})
+				})
+				r.Route("/admin", func(r chi.Router) {
+					r.Use(app.requireRole(store.RoleAdmin))
+					r.Get("/audit", app.getAuditLogHandler)
+					r.Get("/stats", app.getStatsHandler)
+					r.Get("/suspensions", app.getSuspensionsHandler)
+					r.Post("/content/delete", app.deleteContentHandler)
+					r.Get("/users", app.searchUsersHandler)
+					r.Route("/users/{userID}", func(r chi.Router) {
+						r.Use(app.adminUserContextMiddleware)
+						r.Get("/", app.getAdminUserHandler)
+						r.Get("/posts", app.getAdminUserPostsHandler)
+						r.Get("/comments", app.getAdminUserCommentsHandler)
+						r.Get("/followers", app.getAdminUserFollowersHandler)
+						r.Get("/following", app.getAdminUserFollowingHandler)
+						r.Put("/role", app.setUserRoleHandler)
+						r.Put("/verification", app.verifyUserHandler)
+						r.Delete("/verification", app.unverifyUserHandler)
+						r.Put("/deactivation", app.deactivateUserHandler)
+						r.Delete("/deactivation", app.reactivateUserHandler)
+						r.Post("/suspension", app.suspendUserHandler)
+						r.Delete("/suspension", app.liftSuspensionHandler)
+					})
+				})
 			})
 		})
 	})
 	return r
 }
 
-func (app *application) run(mux http.Handler) error {
+type serverKey string
+
+// closingContextKey holds, in every request context, a context that is
+// cancelled once the server starts shutting down.
+var closingContextKey serverKey = "closing"
+
+// run serves the API until ctx is cancelled, then shuts the server down,
+// letting requests in flight finish. Streams and sockets, which would stay
+// open for as long as their clients do, are closed instead; see
+// longLivedContext.
+func (app *application) run(ctx context.Context, mux http.Handler) error {
 	docs.SwaggerInfo.Version = version
 	docs.SwaggerInfo.Host = app.config.apiURL
 	docs.SwaggerInfo.BasePath = "/v1"
+	closing, closeLongLived := context.WithCancel(context.Background())
+	defer closeLongLived()
 	srv := &http.Server{
 		Addr:         app.config.addr,
 		Handler:      mux,
 		WriteTimeout: time.Second * 30,
 		ReadTimeout:  time.Second * 10,
 		IdleTimeout:  time.Minute,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), closingContextKey, closing)
+		},
 	}
+	srv.RegisterOnShutdown(closeLongLived)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		app.logger.Infow("server is shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
 
 	app.logger.Infow("server has started", "address", app.config.addr, "env", app.config.env)
 
-	return srv.ListenAndServe()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if err := <-shutdownErr; err != nil {
+		return err
+	}
+	app.logger.Infow("server has stopped")
+	return nil
+}
+
+// longLivedContext returns the context for a request that stays open until
+// the client leaves, such as a stream. Unlike r.Context(), it is also
+// cancelled when the server starts shutting down, which would otherwise
+// wait for such requests until it gives up.
+func longLivedContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	closing, ok := r.Context().Value(closingContextKey).(context.Context)
+	if !ok {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(closing, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
 }
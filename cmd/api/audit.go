@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// auditMiddleware tags the request context with who is acting and from
+// where, for the store to record alongside audited changes.
+func (app *application) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actorID := getViewerID(r)
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		ctx := store.WithAuditInfo(r.Context(), store.AuditInfo{
+			ActorID:   &actorID,
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        ip,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetAuditLog godoc
+//
+//	@Summary		Lists audit log entries
+//	@Description	Lists who changed what, newest first. Pages continue from the ID of the last entry of the previous page, passed as before. Only admins may use it.
+//	@Tags			admin
+//	@Produce		json
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			before		query		int		false	"Only entries with a lower ID"
+//	@Param			actor_id	query		int		false	"Only changes made by this user"
+//	@Param			target_type	query		string	false	"Only changes to this kind of thing: post, user, webhook or report"
+//	@Param			target_id	query		int		false	"Only changes to the thing with this ID"
+//	@Param			action		query		string	false	"Only this action, e.g. post.deleted"
+//	@Param			since		query		string	false	"Only entries recorded at or after this RFC 3339 time"
+//	@Param			until		query		string	false	"Only entries recorded before this RFC 3339 time"
+//	@Success		200			{object}	[]store.AuditEntry
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit [get]
+func (app *application) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	aq := store.AuditQuery{
+		Limit: 50,
+	}
+	aq, err := aq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(aq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	entries, err := app.store.Audit.Get(r.Context(), aq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, entries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
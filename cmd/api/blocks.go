@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+var errSelfTarget = errors.New("cannot perform this action on yourself")
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID, removing follows in both directions
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedUser := getUserFromCtx(r)
+	viewerID := getViewerID(r)
+	if blockedUser.ID == viewerID {
+		app.statusBadRequest(w, r, errSelfTarget)
+		return
+	}
+	if err := app.store.Blocks.Block(r.Context(), viewerID, blockedUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblocks a user
+//	@Description	Unblocks a user by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unblocked"
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	blockedUser := getUserFromCtx(r)
+	if err := app.store.Blocks.Unblock(r.Context(), getViewerID(r), blockedUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Hides a user's posts and comments without blocking them
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	mutedUser := getUserFromCtx(r)
+	viewerID := getViewerID(r)
+	if mutedUser.ID == viewerID {
+		app.statusBadRequest(w, r, errSelfTarget)
+		return
+	}
+	if err := app.store.Mutes.Mute(r.Context(), viewerID, mutedUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmutes a user
+//	@Description	Unmutes a user by ID
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User unmuted"
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	mutedUser := getUserFromCtx(r)
+	if err := app.store.Mutes.Unmute(r.Context(), getViewerID(r), mutedUser.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
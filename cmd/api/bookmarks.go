@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type BookmarkPostPayload struct {
+	CollectionID *int64 `json:"collection_id" validate:"omitempty,gt=0"`
+}
+
+type CollectionPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post to the current user's bookmarks, optionally in a collection. Bookmarking an already bookmarked post moves it to the given collection.
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Param			postID	path		int					true	"Post ID"
+//	@Param			payload	body		BookmarkPostPayload	false	"Collection to save to"
+//	@Success		204		{string}	string				"Post bookmarked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"post or collection not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	var payload BookmarkPostPayload
+	if r.ContentLength != 0 {
+		if err := readJSON(w, r, &payload); err != nil {
+			app.statusBadRequest(w, r, err)
+			return
+		}
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Bookmarks.Save(r.Context(), getViewerID(r), post.ID, payload.CollectionID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnbookmarkPost godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the current user's bookmarks
+//	@Tags			bookmarks
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Bookmark removed"
+//	@Failure		404		{object}	error	"post not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if err := app.store.Bookmarks.Remove(r.Context(), getViewerID(r), post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBookmarks godoc
+//
+//	@Summary		Lists bookmarks
+//	@Description	Lists the current user's bookmarked posts, most recently saved first
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Param			limit		query		int	false	"Limit"
+//	@Param			before		query		int	false	"Only bookmarks older than this bookmark ID"
+//	@Param			collection	query		int	false	"Only bookmarks in this collection"
+//	@Success		200			{object}	[]store.Bookmark
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	bq := store.BookmarkQuery{
+		Limit: 20,
+	}
+	bq, err := bq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(bq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	bookmarks, err := app.store.Bookmarks.Get(r.Context(), getViewerID(r), bq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, bookmarks); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetCollections godoc
+//
+//	@Summary		Lists bookmark collections
+//	@Description	Lists the current user's bookmark collections
+//	@Tags			bookmarks
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookmarkCollection
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/collections [get]
+func (app *application) getCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	collections, err := app.store.Bookmarks.GetCollections(r.Context(), getViewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, collections); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// CreateCollection godoc
+//
+//	@Summary		Creates a bookmark collection
+//	@Description	Creates a named collection to group bookmarks in
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CollectionPayload	true	"Collection payload"
+//	@Success		201		{object}	store.BookmarkCollection
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"name already used"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/collections [post]
+func (app *application) createCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CollectionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	collection := &store.BookmarkCollection{
+		UserID: getViewerID(r),
+		Name:   payload.Name,
+	}
+	if err := app.store.Bookmarks.CreateCollection(r.Context(), collection); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, collection); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RenameCollection godoc
+//
+//	@Summary		Renames a bookmark collection
+//	@Description	Renames one of the current user's bookmark collections
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			collectionID	path		int					true	"Collection ID"
+//	@Param			payload			body		CollectionPayload	true	"Collection payload"
+//	@Success		200				{object}	store.BookmarkCollection
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error	"collection not found"
+//	@Failure		409				{object}	error	"name already used"
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/collections/{collectionID} [patch]
+func (app *application) renameCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload CollectionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	collection := &store.BookmarkCollection{
+		ID:     collectionID,
+		UserID: getViewerID(r),
+		Name:   payload.Name,
+	}
+	if err := app.store.Bookmarks.RenameCollection(r.Context(), collection); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, collection); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeleteCollection godoc
+//
+//	@Summary		Deletes a bookmark collection
+//	@Description	Deletes one of the current user's bookmark collections. The bookmarks in it are kept.
+//	@Tags			bookmarks
+//	@Param			collectionID	path		int		true	"Collection ID"
+//	@Success		204				{string}	string	"Collection deleted"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error	"collection not found"
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/collections/{collectionID} [delete]
+func (app *application) deleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := strconv.ParseInt(chi.URLParam(r, "collectionID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := app.store.Bookmarks.DeleteCollection(r.Context(), getViewerID(r), collectionID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
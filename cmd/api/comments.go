@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Chandan185/Societal/internal/moderation"
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// createComment godoc
+//
+//	@Summary		Creates comment
+//	@Description	Comment on a post the current user can see
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			comment	body		CreateCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error	"invalid payload"
+//	@Failure		404		{object}	error	"post not found"
+//	@Failure		422		{object}	error	"rejected by the content filter"
+//	@Failure		500		{object}	error	"internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	var payload CreateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	comment := &store.Comment{
+		PostID:  post.ID,
+		UserID:  getViewerID(r),
+		Content: payload.Content,
+	}
+	content := moderation.Content{
+		Kind:   store.ReportTargetComment,
+		UserID: comment.UserID,
+		Fields: []moderation.Field{{Name: "content", Text: comment.Content}},
+	}
+	screening, ok := app.screenContent(w, r, content)
+	if !ok {
+		return
+	}
+	comment.FlagDetails = flagDetails(screening)
+	comment.ContentFingerprint = moderation.Fingerprint(content)
+	if err := app.store.Comments.Create(r.Context(), comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	topics := []string{postTopic(post.ID), userTopic(post.USERID)}
+	for _, m := range comment.Mentions {
+		topics = append(topics, userTopic(m.UserID))
+	}
+	app.publish(r.Context(), topics...)
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type MarkConversationReadPayload struct {
+	UpTo int64 `json:"up_to" validate:"required,gt=0"`
+}
+
+// getConversations godoc
+//
+//	@Summary		Lists conversations
+//	@Description	Lists the current user's direct message conversations, most recently active first
+//	@Tags			messages
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			before	query		int	false	"Only conversations whose last message is older than this message ID"
+//	@Success		200		{object}	[]store.Conversation
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations [get]
+func (app *application) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	mq := store.MessageQuery{
+		Limit: 20,
+	}
+	mq, err := mq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(mq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	conversations, err := app.store.Messages.GetConversations(r.Context(), getViewerID(r), mq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, conversations); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getMessages godoc
+//
+//	@Summary		Lists messages
+//	@Description	Pages through the messages of a conversation, newest first
+//	@Tags			messages
+//	@Produce		json
+//	@Param			conversationID	path		int	true	"Conversation ID"
+//	@Param			limit			query		int	false	"Limit"
+//	@Param			before			query		int	false	"Only messages older than this message ID"
+//	@Success		200				{object}	[]store.Message
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error	"conversation not found"
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/messages [get]
+func (app *application) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	mq := store.MessageQuery{
+		Limit: 50,
+	}
+	mq, err = mq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(mq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	messages, err := app.store.Messages.GetMessages(r.Context(), conversationID, getViewerID(r), mq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, messages); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// markConversationRead godoc
+//
+//	@Summary		Marks messages as read
+//	@Description	Marks every message in a conversation up to and including up_to as read, sending a read receipt to the other member
+//	@Tags			messages
+//	@Accept			json
+//	@Param			conversationID	path		int							true	"Conversation ID"
+//	@Param			payload			body		MarkConversationReadPayload	true	"Last message read"
+//	@Success		204				{string}	string						"Messages marked as read"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error	"conversation not found"
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/{conversationID}/read [post]
+func (app *application) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload MarkConversationReadPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	receipt, err := app.store.Messages.MarkRead(ctx, conversationID, getViewerID(r), payload.UpTo)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if receipt != nil {
+		app.publish(ctx, messagesTopic(receipt.PeerID))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
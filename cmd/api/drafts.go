@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+// GetDrafts godoc
+//
+//	@Summary		Lists unpublished posts
+//	@Description	Lists the current user's scheduled posts, soonest first, followed by their drafts, most recently edited first. Drafts are edited and published through PATCH /posts/{postID}.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			status	query		string	false	"Only draft or scheduled posts"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/drafts [get]
+func (app *application) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	dq := store.DraftQuery{
+		Limit: 20,
+	}
+	dq, err := dq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(dq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	drafts, err := app.store.Posts.GetDrafts(r.Context(), getViewerID(r), dq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, drafts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -23,3 +23,50 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 	app.logger.Errorf("conflict response", "method", r.Method, "path", r.URL.Path, "error", err)
 	writeJsonError(w, http.StatusConflict, err.Error())
 }
+
+func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("forbidden", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusForbidden, err.Error())
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("precondition failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusPreconditionFailed, err.Error())
+}
+
+// versionConflictResponse reports a lost optimistic concurrency race: as a
+// failed precondition when the client sent If-Match, otherwise as a
+// conflict.
+func (app *application) versionConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Header.Get("If-Match") != "" {
+		app.preconditionFailedResponse(w, r, err)
+		return
+	}
+	app.conflictResponse(w, r, err)
+}
+
+// suspendedResponse reports that the requested account is suspended or
+// deactivated.
+func (app *application) suspendedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("suspended account", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJsonError(w, http.StatusGone, err.Error())
+}
+
+// contentRejectedResponse reports content the content filter rejected, with
+// the reason each rejected field was rejected.
+func (app *application) contentRejectedResponse(w http.ResponseWriter, r *http.Request, fields map[string]string) {
+	app.logger.Warnw("content rejected", "method", r.Method, "path", r.URL.Path, "fields", fields)
+	type envelope struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, &envelope{
+		Error:  "content was rejected by the content filter",
+		Fields: fields,
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+// postETag identifies a version of a post. It changes whenever the post
+// itself is edited; comments and other data embedded in responses do not
+// affect it. Publishing resets the version, so unpublished posts carry their
+// status as well.
+func postETag(post *store.Post) string {
+	if post.Status != store.PostStatusPublished {
+		return fmt.Sprintf(`"%d-%d-%s"`, post.ID, post.Version, post.Status)
+	}
+	return fmt.Sprintf(`"%d-%d"`, post.ID, post.Version)
+}
+
+// etagDigest matches the body digest a representation ETag adds to a
+// post's ETag.
+var etagDigest = regexp.MustCompile(`\.[0-9a-f]{16}"`)
+
+// representationETag identifies a full response about post. It starts with
+// postETag, which If-Match compares, and adds a digest of body, so comments,
+// bookmarks, embedded posts and media in the response change it too.
+func representationETag(post *store.Post, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.TrimSuffix(postETag(post), `"`) + "." + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagsMatch reports whether header, the value of an If-Match or
+// If-None-Match header, is "*" or lists etag. Weak entity tags only match
+// when weak comparison is allowed.
+func etagsMatch(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match precondition of a write to post,
+// responding with 412 and reporting false when the client's copy is stale.
+// Only the post itself is compared, so the ETag of a full response matches
+// as long as the post was not edited since.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	header := etagDigest.ReplaceAllString(r.Header.Get("If-Match"), `"`)
+	if header == "" || etagsMatch(header, postETag(post), false) {
+		return true
+	}
+	app.preconditionFailedResponse(w, r, store.ErrVersionConflict)
+	return false
+}
@@ -42,7 +42,7 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	ctx := r.Context()
-	posts, err := app.store.Posts.GetUserFeed(ctx, int64(45), fq)
+	posts, err := app.store.Posts.GetUserFeed(ctx, getViewerID(r), fq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
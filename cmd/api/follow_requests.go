@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdatePrivacyPayload struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// requestFollow records a follow request against a private account, unless
+// followerID already follows it.
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, followerID, userID int64) {
+	ctx := r.Context()
+	following, err := app.store.Followers.IsFollowing(ctx, followerID, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if following {
+		app.conflictResponse(w, r, store.ErrConflict)
+		return
+	}
+
+	req := &store.FollowRequest{
+		RequesterID: followerID,
+		UserID:      userID,
+	}
+	if err := app.store.FollowRequests.Create(ctx, req); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.publish(ctx, userTopic(userID))
+
+	if err := app.jsonResponse(w, http.StatusAccepted, req); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdatePrivacy godoc
+//
+//	@Summary		Updates account privacy
+//	@Description	Makes the current user's account private or public. Making it public approves all pending follow requests.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdatePrivacyPayload	true	"Privacy payload"
+//	@Success		204		{string}	string					"Privacy updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/privacy [put]
+func (app *application) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdatePrivacyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := app.store.Users.SetPrivate(r.Context(), getViewerID(r), *payload.IsPrivate); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFollowRequests godoc
+//
+//	@Summary		Lists pending follow requests
+//	@Description	Lists the follow requests waiting on the current user's approval
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.FollowRequest
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	requests, err := app.store.FollowRequests.GetPending(r.Context(), getViewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, requests); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approves a follow request
+//	@Description	Approves a pending follow request, making the requester a follower
+//	@Tags			users
+//	@Produce		json
+//	@Param			requesterID	path		int		true	"Requester ID"
+//	@Success		204			{string}	string	"Follow request approved"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error	"user is blocked"
+//	@Failure		404			{object}	error	"follow request not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{requesterID} [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.FollowRequests.Approve)
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Rejects a follow request
+//	@Description	Rejects a pending follow request
+//	@Tags			users
+//	@Produce		json
+//	@Param			requesterID	path		int		true	"Requester ID"
+//	@Success		204			{string}	string	"Follow request rejected"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"follow request not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests/{requesterID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.FollowRequests.Reject)
+}
+
+func (app *application) resolveFollowRequest(w http.ResponseWriter, r *http.Request, resolve func(ctx context.Context, userID, requesterID int64) error) {
+	requesterID, err := strconv.ParseInt(chi.URLParam(r, "requesterID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := resolve(ctx, getViewerID(r), requesterID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrBlocked):
+			app.forbiddenResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	// An approval notifies the requester.
+	app.publish(ctx, userTopic(requesterID))
+	w.WriteHeader(http.StatusNoContent)
+}
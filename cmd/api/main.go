@@ -1,11 +1,24 @@
 package main
 
 import (
-	"log"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/Chandan185/Societal/internal/blob"
 	"github.com/Chandan185/Societal/internal/db"
 	"github.com/Chandan185/Societal/internal/env"
+	"github.com/Chandan185/Societal/internal/events"
+	"github.com/Chandan185/Societal/internal/jobs"
+	"github.com/Chandan185/Societal/internal/media"
+	"github.com/Chandan185/Societal/internal/moderation"
+	"github.com/Chandan185/Societal/internal/pubsub"
+	"github.com/Chandan185/Societal/internal/scheduler"
 	"github.com/Chandan185/Societal/internal/store"
+	"github.com/Chandan185/Societal/internal/trash"
+	"github.com/Chandan185/Societal/internal/webhooks"
 	"go.uber.org/zap"
 )
 
@@ -39,12 +52,63 @@ func main() {
 		},
 		env:    env.GetString("ENV", "development"),
 		apiURL: env.GetString("API_URL", "localhost:8000"),
+		media: mediaConfig{
+			dir:            env.GetString("MEDIA_DIR", "./uploads"),
+			maxUploadBytes: int64(env.GetInt("MEDIA_MAX_UPLOAD_BYTES", 10<<20)),
+			workers:        env.GetInt("MEDIA_WORKERS", 2),
+		},
+		stream: streamConfig{
+			broker: env.GetString("STREAM_BROKER", "memory"),
+		},
+		jobs: jobsConfig{
+			concurrency: env.GetInt("JOBS_CONCURRENCY", 4),
+		},
+		webhooks: webhooksConfig{
+			disableAfter: env.GetInt("WEBHOOK_DISABLE_AFTER", 15),
+		},
+		moderation: moderationConfig{
+			reportHideThreshold: env.GetInt("REPORT_HIDE_THRESHOLD", 5),
+			rulesFile:           env.GetString("MODERATION_RULES_FILE", ""),
+		},
 	}
 
 	//Logger
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer logger.Sync()
 
+	retention, err := time.ParseDuration(env.GetString("TRASH_RETENTION", "720h"))
+	if err != nil {
+		logger.Fatal("Invalid TRASH_RETENTION:", err)
+	}
+	purgeInterval, err := time.ParseDuration(env.GetString("TRASH_PURGE_INTERVAL", "1h"))
+	if err != nil {
+		logger.Fatal("Invalid TRASH_PURGE_INTERVAL:", err)
+	}
+	cnf.trash = trashConfig{
+		retention:     retention,
+		purgeInterval: purgeInterval,
+	}
+	cnf.scheduleInterval, err = time.ParseDuration(env.GetString("SCHEDULE_INTERVAL", "30s"))
+	if err != nil {
+		logger.Fatal("Invalid SCHEDULE_INTERVAL:", err)
+	}
+	cnf.jobs.timeout, err = time.ParseDuration(env.GetString("JOBS_TIMEOUT", "1m"))
+	if err != nil {
+		logger.Fatal("Invalid JOBS_TIMEOUT:", err)
+	}
+	cnf.events.pollInterval, err = time.ParseDuration(env.GetString("OUTBOX_POLL_INTERVAL", "1s"))
+	if err != nil {
+		logger.Fatal("Invalid OUTBOX_POLL_INTERVAL:", err)
+	}
+	cnf.events.retention, err = time.ParseDuration(env.GetString("OUTBOX_RETENTION", "168h"))
+	if err != nil {
+		logger.Fatal("Invalid OUTBOX_RETENTION:", err)
+	}
+	cnf.webhooks.timeout, err = time.ParseDuration(env.GetString("WEBHOOK_TIMEOUT", "10s"))
+	if err != nil {
+		logger.Fatal("Invalid WEBHOOK_TIMEOUT:", err)
+	}
+
 	//database
 	db, err := db.New(cnf.db.addr, cnf.db.maxIdleTime, cnf.db.maxOpenConn, cnf.db.maxIdleConn)
 	if err != nil {
@@ -53,11 +117,96 @@ func main() {
 	defer db.Close()
 	logger.Info("Database connection pool established")
 	store := store.NewStorage(db)
+
+	//background jobs
+	jobPool := jobs.NewPool(db, logger, jobs.Config{
+		Concurrency: cnf.jobs.concurrency,
+		Timeout:     cnf.jobs.timeout,
+	})
+
+	//domain events
+	relay := events.NewRelay(db, logger, events.Config{
+		PollInterval: cnf.events.pollInterval,
+		Retention:    cnf.events.retention,
+	})
+
+	//webhooks
+	dispatcher := webhooks.NewDispatcher(store, webhooks.NewClient(cnf.webhooks.timeout), logger, cnf.webhooks.disableAfter)
+	dispatcher.Register(relay, jobPool)
+
+	//content filtering
+	contentFilter, err := moderation.Load(cnf.moderation.rulesFile, store.Reports)
+	if err != nil {
+		logger.Fatal("Error loading moderation rules:", err)
+	}
+
+	//blob storage
+	blobs, err := blob.NewFileSystemStore(cnf.media.dir)
+	if err != nil {
+		logger.Fatal("Error opening media directory:", err)
+	}
+
+	//background work stops when the server is asked to shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	//media processing
+	mediaProcessor := media.NewProcessor(store, blobs, logger, cnf.media.workers)
+	go mediaProcessor.Run(ctx)
+
+	//trash purging
+	purger := trash.NewPurger(store, blobs, logger, cnf.trash.retention, cnf.trash.purgeInterval)
+	go purger.Run(ctx)
+
+	//stream broker
+	var broker pubsub.Broker
+	switch cnf.stream.broker {
+	case "postgres":
+		pgBroker, err := pubsub.NewPostgresBroker(db, cnf.db.addr, logger)
+		if err != nil {
+			logger.Fatal("Error listening for stream events:", err)
+		}
+		defer pgBroker.Close()
+		broker = pgBroker
+	default:
+		broker = pubsub.NewMemoryBroker()
+	}
+
 	app := &application{
-		config: cnf,
-		store:  store,
-		logger: logger,
+		config:         cnf,
+		store:          store,
+		blobs:          blobs,
+		mediaProcessor: mediaProcessor,
+		broker:         broker,
+		jobs:           jobPool,
+		events:         relay,
+		contentFilter:  contentFilter,
+		logger:         logger,
 	}
+
+	//scheduled posts
+	postScheduler := scheduler.NewScheduler(store, logger, cnf.scheduleInterval, app.postPublished)
+	go postScheduler.Run(ctx)
+
+	//job handlers and event subscribers are registered by now, so the pool
+	//and the relay can start. On shutdown the pool stops claiming jobs and
+	//finishes the ones it is running.
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		jobPool.Run(ctx)
+	}()
+	go relay.Run(ctx)
+
 	mux := app.mount()
-	log.Fatal(app.run(mux))
+	serveErr := app.run(ctx, mux)
+	if serveErr != nil {
+		logger.Errorw("server stopped with an error", "error", serveErr)
+		stop()
+	}
+	<-jobsDone
+	logger.Info("Background jobs drained")
+	if serveErr != nil {
+		os.Exit(1)
+	}
 }
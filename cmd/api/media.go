@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/media"
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi/v5"
+)
+
+// allowedMediaTypes are the sniffed content types accepted for upload.
+var allowedMediaTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"video/mp4",
+}
+
+var (
+	errMediaTooLarge    = errors.New("file is too large")
+	errMediaBadType     = errors.New("file type is not supported")
+	errMediaMissingFile = errors.New("missing file field")
+	errMediaNoVariant   = errors.New("media variant not found")
+)
+
+// uploadMedia godoc
+//
+//	@Summary		Uploads media
+//	@Description	Uploads an image or video that can then be attached to a post through media_ids
+//	@Tags			media
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file	formData	file	true	"Media file"
+//	@Success		201		{object}	store.Media
+//	@Failure		400		{object}	error	"invalid file"
+//	@Failure		413		{object}	error	"file too large"
+//	@Failure		500		{object}	error	"internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := app.config.media.maxUploadBytes
+	// Leave some room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			app.payloadTooLargeResponse(w, r, errMediaTooLarge)
+		case errors.Is(err, http.ErrMissingFile):
+			app.statusBadRequest(w, r, errMediaMissingFile)
+		default:
+			app.statusBadRequest(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxBytes {
+		app.payloadTooLargeResponse(w, r, errMediaTooLarge)
+		return
+	}
+
+	mtype, err := mimetype.DetectReader(file)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !mimetype.EqualsAny(mtype.String(), allowedMediaTypes...) {
+		app.statusBadRequest(w, r, errMediaBadType)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	viewerID := getViewerID(r)
+	key, err := newMediaKey(viewerID, mtype.Extension())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.blobs.Put(ctx, key, file); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Images are published once the processor has stripped their metadata
+	// and rendered thumbnails; other media is usable as uploaded.
+	status := store.MediaStatusReady
+	if media.IsImage(mtype.String()) {
+		status = store.MediaStatusPending
+	}
+	m := &store.Media{
+		UserID:      viewerID,
+		StorageKey:  key,
+		ContentType: mtype.String(),
+		SizeBytes:   header.Size,
+		Status:      status,
+	}
+	if err := app.store.Media.Create(ctx, m); err != nil {
+		if delErr := app.blobs.Delete(ctx, key); delErr != nil {
+			app.logger.Errorw("failed to remove orphaned blob", "key", key, "error", delErr)
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+	if status == store.MediaStatusPending {
+		app.mediaProcessor.Notify()
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, m); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getMedia godoc
+//
+//	@Summary		Fetches media
+//	@Description	Streams an uploaded media file. Media attached to a post is only served to viewers who can see the post.
+//	@Tags			media
+//	@Produce		octet-stream
+//	@Param			mediaID	path		int		true	"Media ID"
+//	@Param			variant	query		string	false	"Thumbnail variant (small, medium, large)"
+//	@Success		200		{file}		file
+//	@Failure		404		{object}	error	"media not found"
+//	@Failure		500		{object}	error	"internal server error"
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [get]
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	m, err := app.store.Media.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	viewerID := getViewerID(r)
+	// Only the uploader sees media before it is processed.
+	visible := m.UserID == viewerID
+	if !visible && m.PostID != nil && m.Status == store.MediaStatusReady {
+		visible, err = app.store.Posts.IsVisibleTo(ctx, *m.PostID, viewerID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if !visible {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	key, contentType, size := m.StorageKey, m.ContentType, m.SizeBytes
+	if name := r.URL.Query().Get("variant"); name != "" {
+		found := false
+		for _, v := range m.Variants {
+			if v.Name == name {
+				key, contentType, size = v.StorageKey, v.ContentType, v.SizeBytes
+				found = true
+				break
+			}
+		}
+		if !found {
+			app.notFoundResponse(w, r, errMediaNoVariant)
+			return
+		}
+	}
+
+	rc, err := app.blobs.Get(ctx, key)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, rc); err != nil {
+		app.logger.Warnw("failed to stream media", "id", m.ID, "error", err)
+	}
+}
+
+// newMediaKey returns a random, unguessable storage key for a file uploaded
+// by userID.
+func newMediaKey(userID int64, ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%s%s", userID, hex.EncodeToString(b), ext), nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteTimeout bounds each write to a socket; clients that cannot keep
+	// up are disconnected and catch up when they reconnect.
+	wsWriteTimeout = 10 * time.Second
+	// wsPongTimeout is how long a socket may stay silent before it is
+	// considered dead. Pings are sent often enough to keep it alive.
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = wsPongTimeout * 9 / 10
+	wsMaxFrameSize = 4096
+	// wsMaxPendingFrames is how many client frames may wait to be handled
+	// before the socket stops reading, pushing back on the client.
+	wsMaxPendingFrames = 8
+	// wsBatchSize is how many messages and receipts are read per wake-up.
+	wsBatchSize = 50
+)
+
+var (
+	upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+
+	errUnknownFrame = errors.New("unknown frame type")
+)
+
+func messagesTopic(id int64) string { return fmt.Sprintf("messages:%d", id) }
+
+type SendMessagePayload struct {
+	RecipientID int64  `json:"recipient_id" validate:"required,gt=0"`
+	Content     string `json:"content" validate:"required,max=1000"`
+}
+
+// wsClientFrame is a frame sent by a client. "send" frames carry a
+// SendMessagePayload and an optional ClientID echoed back in the reply;
+// "read" frames mark a conversation read up to UpTo.
+type wsClientFrame struct {
+	Type           string `json:"type"`
+	ClientID       string `json:"client_id"`
+	RecipientID    int64  `json:"recipient_id"`
+	Content        string `json:"content"`
+	ConversationID int64  `json:"conversation_id"`
+	UpTo           int64  `json:"up_to"`
+}
+
+// wsServerFrame is a frame sent to a client: "message" for a new message in
+// one of their conversations, "sent" acknowledging a "send" frame,
+// "receipt" when the other member of a conversation received or read
+// messages, and "error" when a frame could not be handled.
+type wsServerFrame struct {
+	Type     string         `json:"type"`
+	ClientID string         `json:"client_id,omitempty"`
+	Message  *store.Message `json:"message,omitempty"`
+	Receipt  *store.Receipt `json:"receipt,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// messagesSocket is the state of one connected client.
+type messagesSocket struct {
+	conn     *websocket.Conn
+	userID   int64
+	messages store.Position
+	receipts store.Position
+	// sent holds messages sent over this socket, which were already
+	// acknowledged and must not be echoed back as new messages.
+	sent map[int64]bool
+}
+
+func (s *messagesSocket) write(frame wsServerFrame) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(frame)
+}
+
+// messagesSocket godoc
+//
+//	@Summary		Opens a direct messaging socket
+//	@Description	Upgrades to a WebSocket for sending and receiving direct messages. On connect, undelivered messages are sent and marked delivered. Send {"type":"send","recipient_id":2,"content":"hi","client_id":"1"} to send a message and {"type":"read","conversation_id":1,"up_to":10} to mark messages read. The server sends "message", "sent", "receipt" and "error" frames.
+//	@Tags			messages
+//	@Success		101	{string}	string	"Switching protocols"
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/conversations/ws [get]
+func (app *application) messagesSocketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := longLivedContext(r)
+	defer cancel()
+	viewerID := getViewerID(r)
+
+	messages, receipts, err := app.store.Messages.SyncCursors(ctx, viewerID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied with an error.
+		return
+	}
+	defer conn.Close()
+
+	sub := app.broker.Subscribe(messagesTopic(viewerID))
+	defer sub.Close()
+
+	s := &messagesSocket{
+		conn:     conn,
+		userID:   viewerID,
+		messages: messages,
+		receipts: receipts,
+		sent:     map[int64]bool{},
+	}
+	frames := make(chan wsClientFrame, wsMaxPendingFrames)
+	go readClientFrames(conn, frames)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	more := true
+	for {
+		if more {
+			more, err = app.syncMessages(ctx, s)
+			if err != nil {
+				app.logger.Warnw("messages socket closed", "error", err, "user", viewerID)
+				return
+			}
+			if more {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			if err := app.handleClientFrame(ctx, s, frame); err != nil {
+				app.logger.Warnw("messages socket closed", "error", err, "user", viewerID)
+				return
+			}
+		case <-sub.C:
+			more = true
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+			// Messages held back behind a transaction that was still
+			// running have no wake-up of their own.
+			more = true
+		}
+	}
+}
+
+// readClientFrames reads frames from conn into frames until the connection
+// fails or stops answering pings, then closes frames. Frames that are not
+// valid JSON are passed on with an empty type.
+func readClientFrames(conn *websocket.Conn, frames chan<- wsClientFrame) {
+	defer close(frames)
+	conn.SetReadLimit(wsMaxFrameSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var frame wsClientFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			frame = wsClientFrame{}
+		}
+		frames <- frame
+	}
+}
+
+// syncMessages sends the messages and receipts that arrived since the
+// socket's cursors, marking incoming messages delivered. It reports whether
+// a batch was full and more may be waiting.
+func (app *application) syncMessages(ctx context.Context, s *messagesSocket) (bool, error) {
+	messages, err := app.store.Messages.GetSince(ctx, s.userID, s.messages, wsBatchSize)
+	if err != nil {
+		return false, err
+	}
+	delivered := map[int64]int64{}
+	for i := range messages {
+		m := &messages[i]
+		s.messages = store.Position{TxID: m.TxID, ID: m.ID}
+		if s.sent[m.ID] {
+			delete(s.sent, m.ID)
+			continue
+		}
+		if m.RecipientID == s.userID && !m.Delivered {
+			delivered[m.ConversationID] = m.ID
+			m.Delivered = true
+		}
+		if err := s.write(wsServerFrame{Type: "message", Message: m}); err != nil {
+			return false, err
+		}
+	}
+	for conversationID, upTo := range delivered {
+		receipt, err := app.store.Messages.MarkDelivered(ctx, conversationID, s.userID, upTo)
+		if err != nil {
+			return false, err
+		}
+		if receipt != nil {
+			app.publish(ctx, messagesTopic(receipt.PeerID))
+		}
+	}
+
+	receipts, err := app.store.Messages.GetReceiptsSince(ctx, s.userID, s.receipts, wsBatchSize)
+	if err != nil {
+		return false, err
+	}
+	for i := range receipts {
+		s.receipts = store.Position{TxID: receipts[i].TxID, ID: receipts[i].Seq}
+		if err := s.write(wsServerFrame{Type: "receipt", Receipt: &receipts[i]}); err != nil {
+			return false, err
+		}
+	}
+	return len(messages) == wsBatchSize || len(receipts) == wsBatchSize, nil
+}
+
+// handleClientFrame acts on one frame from the client. Problems with the
+// frame itself are reported back as error frames; only a failure to write
+// to the socket is returned.
+func (app *application) handleClientFrame(ctx context.Context, s *messagesSocket, frame wsClientFrame) error {
+	switch frame.Type {
+	case "send":
+		payload := SendMessagePayload{RecipientID: frame.RecipientID, Content: frame.Content}
+		if err := Validator.Struct(payload); err != nil {
+			return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: err.Error()})
+		}
+		if payload.RecipientID == s.userID {
+			return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: errSelfTarget.Error()})
+		}
+		msg := &store.Message{
+			SenderID:    s.userID,
+			RecipientID: payload.RecipientID,
+			Content:     payload.Content,
+		}
+		if err := app.store.Messages.Send(ctx, msg); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrBlocked), errors.Is(err, store.ErrPrivateAccount):
+				return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: err.Error()})
+			default:
+				app.logger.Errorw("failed to send message", "error", err, "user", s.userID)
+				return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: "the message could not be sent"})
+			}
+		}
+		s.sent[msg.ID] = true
+		// The sender's topic reaches their other open sockets.
+		app.publish(ctx, messagesTopic(msg.RecipientID), messagesTopic(s.userID))
+		return s.write(wsServerFrame{Type: "sent", ClientID: frame.ClientID, Message: msg})
+	case "read":
+		receipt, err := app.store.Messages.MarkRead(ctx, frame.ConversationID, s.userID, frame.UpTo)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: err.Error()})
+			default:
+				app.logger.Errorw("failed to mark messages read", "error", err, "user", s.userID)
+				return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: "the messages could not be marked as read"})
+			}
+		}
+		if receipt != nil {
+			app.publish(ctx, messagesTopic(receipt.PeerID))
+		}
+		return nil
+	default:
+		return s.write(wsServerFrame{Type: "error", ClientID: frame.ClientID, Error: errUnknownFrame.Error()})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/moderation"
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var errNotPermitted = errors.New("you do not have permission to do that")
+
+// requireRole only lets through users with one of roles.
+func (app *application) requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			viewer, err := app.store.Users.GetByID(r.Context(), getViewerID(r))
+			if err != nil {
+				switch {
+				case errors.Is(err, store.ErrNotFound):
+					app.forbiddenResponse(w, r, errNotPermitted)
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+			if !slices.Contains(roles, viewer.Role) {
+				app.forbiddenResponse(w, r, errNotPermitted)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// screenContent runs content through the content filter. It answers 422,
+// naming the rejected fields, and returns false when the filter rejects it.
+func (app *application) screenContent(w http.ResponseWriter, r *http.Request, content moderation.Content) (moderation.Result, bool) {
+	result, err := app.contentFilter.Check(r.Context(), content)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return result, false
+	}
+	if result.Decision == moderation.Reject {
+		app.contentRejectedResponse(w, r, result.Rejections())
+		return result, false
+	}
+	return result, true
+}
+
+// flagDetails returns what to file an automated report with when the
+// filter flagged content, or "" when it did not. The report is filed in the
+// same transaction that saves the content.
+func flagDetails(result moderation.Result) string {
+	if result.Decision != moderation.Flag {
+		return ""
+	}
+	return result.Summary()
+}
+
+type AssignReportPayload struct {
+	// ModeratorID is who takes the report; null returns it to the queue.
+	ModeratorID *int64 `json:"moderator_id" validate:"omitempty,gte=1"`
+}
+
+type ModerationActionPayload struct {
+	Action string `json:"action" validate:"required,oneof=dismiss hide warn suspend"`
+	Note   string `json:"note" validate:"max=1000"`
+	// SuspendDays is how long a suspension lasts. Leaving it out suspends
+	// the user indefinitely.
+	SuspendDays int `json:"suspend_days" validate:"omitempty,gte=1,lte=3650"`
+}
+
+// GetReportQueue godoc
+//
+//	@Summary		Lists reports
+//	@Description	Lists reports for moderators, oldest first. Only moderators and admins may use it.
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Param			status		query		string	false	"Only reports with this status: open (the default), resolved or dismissed"
+//	@Param			target_type	query		string	false	"Only reports on a post, comment or user"
+//	@Param			reason		query		string	false	"Only reports with this reason"
+//	@Param			assignee	query		string	false	"Only reports assigned to a moderator ID, to me or unassigned"
+//	@Success		200			{object}	[]store.Report
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports [get]
+func (app *application) getReportQueueHandler(w http.ResponseWriter, r *http.Request) {
+	rq := store.ReportQuery{
+		Limit:  20,
+		Status: store.ReportStatusOpen,
+	}
+	rq, err := rq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if r.URL.Query().Get("assignee") == "me" {
+		rq.AssigneeID = getViewerID(r)
+	}
+	if err := Validator.Struct(rq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	reports, err := app.store.Reports.GetQueue(r.Context(), rq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, reports); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetReport godoc
+//
+//	@Summary		Fetches a report
+//	@Description	Fetches a report with the moderation actions taken on it
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			reportID	path		int	true	"Report ID"
+//	@Success		200			{object}	store.Report
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error	"report not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{reportID} [get]
+func (app *application) getReportHandler(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	report, err := app.store.Reports.GetByID(r.Context(), reportID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// AssignReport godoc
+//
+//	@Summary		Assigns a report
+//	@Description	Assigns a report to a moderator, or returns it to the queue when moderator_id is null
+//	@Tags			moderation
+//	@Accept			json
+//	@Param			reportID	path		int					true	"Report ID"
+//	@Param			payload		body		AssignReportPayload	true	"Assignee"
+//	@Success		204			{string}	string				"Report assigned"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error	"report not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{reportID}/assignee [put]
+func (app *application) assignReportHandler(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload AssignReportPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Reports.Assign(r.Context(), reportID, payload.ModeratorID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrNotModerator):
+			app.statusBadRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ActOnReport godoc
+//
+//	@Summary		Acts on a report
+//	@Description	Resolves every open report on the reported content. dismiss leaves it be, making it visible again if it was hidden; hide hides a post or comment from everyone but its author; warn notifies the author; suspend suspends them for suspend_days, or indefinitely. The action is recorded with the acting moderator.
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			reportID	path		int						true	"Report ID"
+//	@Param			payload		body		ModerationActionPayload	true	"Action"
+//	@Success		201			{object}	store.ModerationAction
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error	"report not found"
+//	@Failure		409			{object}	error	"report already closed or user already suspended"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/reports/{reportID}/actions [post]
+func (app *application) actOnReportHandler(w http.ResponseWriter, r *http.Request) {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload ModerationActionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	moderatorID := getViewerID(r)
+	action := &store.ModerationAction{
+		ReportID:    &reportID,
+		ModeratorID: &moderatorID,
+		Action:      payload.Action,
+		Note:        payload.Note,
+	}
+	var suspendUntil *time.Time
+	if payload.Action == store.ModerationSuspend && payload.SuspendDays > 0 {
+		until := time.Now().AddDate(0, 0, payload.SuspendDays)
+		suspendUntil = &until
+	}
+	if err := app.store.Reports.Act(r.Context(), action, suspendUntil); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidAction):
+			app.statusBadRequest(w, r, err)
+		case errors.Is(err, store.ErrReportClosed), errors.Is(err, store.ErrAlreadySuspended):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, action); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
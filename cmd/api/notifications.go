@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+type MarkNotificationsReadPayload struct {
+	IDs  []int64 `json:"ids" validate:"max=100"`
+	UpTo int64   `json:"up_to" validate:"gte=0"`
+}
+
+var errNothingToMark = errors.New("either ids or up_to is required")
+
+// getNotifications godoc
+//
+//	@Summary		Lists notifications
+//	@Description	Lists the current user's notifications, newest first. Follows and comments on the same post are grouped per day.
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			before	query		int		false	"Only groups older than this latest_id"
+//	@Param			type	query		string	false	"Comma separated notification types"
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Success		200		{object}	[]store.NotificationGroup
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	nq := store.NotificationQuery{
+		Limit: 20,
+	}
+	nq, err := nq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(nq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	groups, err := app.store.Notifications.GetGroups(r.Context(), getViewerID(r), nq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range groups {
+		groups[i].Summary = summarizeNotification(groups[i])
+	}
+	if err := app.jsonResponse(w, http.StatusOK, groups); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// markNotificationsRead godoc
+//
+//	@Summary		Marks notifications as read
+//	@Description	Marks the given notification IDs, and/or every notification up to and including up_to, as read
+//	@Tags			notifications
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MarkNotificationsReadPayload	true	"Notifications to mark"
+//	@Success		200		{object}	map[string]int64
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/read [post]
+func (app *application) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkNotificationsReadPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if len(payload.IDs) == 0 && payload.UpTo == 0 {
+		app.statusBadRequest(w, r, errNothingToMark)
+		return
+	}
+
+	marked, err := app.store.Notifications.MarkRead(r.Context(), getViewerID(r), payload.IDs, payload.UpTo)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, map[string]int64{"marked": marked}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getUnreadNotificationCount godoc
+//
+//	@Summary		Counts unread notifications
+//	@Description	Returns how many of the current user's notifications are unread
+//	@Tags			notifications
+//	@Produce		json
+//	@Success		200	{object}	map[string]int64
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/unread-count [get]
+func (app *application) getUnreadNotificationCountHandler(w http.ResponseWriter, r *http.Request) {
+	count, err := app.store.Notifications.UnreadCount(r.Context(), getViewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, map[string]int64{"unread": count}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// summarizeNotification renders a group as text, e.g. "user1 and 4 others
+// commented on your post".
+func summarizeNotification(g store.NotificationGroup) string {
+	if len(g.Actors) == 0 {
+		return ""
+	}
+	if g.Type == store.NotificationWarning {
+		// The actor is the user themselves; moderators are not named.
+		if g.CommentID != nil {
+			return "a moderator warned you about your comment"
+		}
+		if g.PostID != nil {
+			return "a moderator warned you about your post"
+		}
+		return "a moderator warned you about your account"
+	}
+	who := g.Actors[0].Username
+	switch others := g.ActorCount - 1; {
+	case others == 1:
+		who += " and 1 other"
+	case others > 1:
+		who += fmt.Sprintf(" and %d others", others)
+	}
+
+	switch g.Type {
+	case store.NotificationFollow:
+		return who + " followed you"
+	case store.NotificationFollowRequest:
+		return who + " requested to follow you"
+	case store.NotificationFollowAccepted:
+		return who + " accepted your follow request"
+	case store.NotificationComment:
+		return who + " commented on your post"
+	case store.NotificationRepost:
+		return who + " reposted your post"
+	case store.NotificationQuote:
+		return who + " quoted your post"
+	case store.NotificationMention:
+		if g.CommentID != nil {
+			return who + " mentioned you in a comment"
+		}
+		return who + " mentioned you in a post"
+	default:
+		return who
+	}
+}
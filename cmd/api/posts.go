@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Chandan185/Societal/internal/moderation"
 	"github.com/Chandan185/Societal/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -14,27 +17,52 @@ type PostKey string
 
 const PostCtx PostKey = "post"
 
+var (
+	errRepostNotEditable    = errors.New("reposts cannot be edited")
+	errPostPublished        = errors.New("published posts cannot be unpublished or rescheduled")
+	errPublishAtRequired    = errors.New("scheduled posts need a publish_at time")
+	errPublishAtPast        = errors.New("publish_at must be in the future")
+	errPublishAtUnscheduled = errors.New("publish_at can only be set on scheduled posts")
+	errNotPostAuthor        = errors.New("only the author can edit or delete a post")
+)
+
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title        string   `json:"title" validate:"required,max=100"`
+	Content      string   `json:"content" validate:"required,max=1000"`
+	Tags         []string `json:"tags"`
+	Visibility   string   `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	MediaIDs     []int64  `json:"media_ids" validate:"max=4,unique"`
+	QuotedPostID *int64   `json:"quoted_post_id" validate:"omitempty,gt=0"`
+	// Status defaults to published. Scheduled posts are published at
+	// PublishAt; drafts stay unpublished until edited.
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title      *string `json:"title" validate:"omitempty,max=100"`
+	Content    *string `json:"content" validate:"omitempty,max=1000"`
+	Visibility *string `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+	// Status and PublishAt reschedule a draft or scheduled post, or publish
+	// it right away.
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+	// Version is the version the edit is based on. If-Match can be sent
+	// instead.
+	Version *int64 `json:"version" validate:"omitempty,gte=0"`
 }
 
 // createPost godoc
 //
 //	@Summary		Creates post
-//	@Description	Create a new post
+//	@Description	Create a new post, or save it as a draft or scheduled post that only its author sees until it is published
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
 //	@Param			post	body		CreatePostPayload	true	"Post payload"
 //	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error	"invalid payload"
+//	@Failure		400		{object}	error	"invalid payload or quoted post not found"
+//	@Failure		422		{object}	error	"rejected by the content filter"
 //	@Failure		500		{object}	error	"internal server error"
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -51,17 +79,55 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		USERID:  1,
-		Tags:    payload.Tags,
+		Title:        payload.Title,
+		Content:      payload.Content,
+		USERID:       getViewerID(r),
+		Tags:         payload.Tags,
+		Visibility:   payload.Visibility,
+		MediaIDs:     payload.MediaIDs,
+		QuotedPostID: payload.QuotedPostID,
+		Status:       payload.Status,
+		PublishAt:    payload.PublishAt,
+	}
+	if post.Visibility == "" {
+		post.Visibility = store.VisibilityPublic
+	}
+	if post.Status == "" {
+		post.Status = store.PostStatusPublished
+	}
+	if err := checkPublishAt(post); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	content := moderation.Content{
+		Kind:   store.ReportTargetPost,
+		UserID: post.USERID,
+		Fields: []moderation.Field{{Name: "title", Text: post.Title}, {Name: "content", Text: post.Content}},
+	}
+	screening, ok := app.screenContent(w, r, content)
+	if !ok {
+		return
 	}
+	post.FlagDetails = flagDetails(screening)
+	post.ContentFingerprint = moderation.Fingerprint(content)
 
 	ctx := r.Context()
 	if err := app.store.Posts.Create(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidMedia), errors.Is(err, store.ErrInvalidQuote):
+			app.statusBadRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.store.Posts.LoadEmbedded(ctx, post.USERID, []*store.Post{post}); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	if post.Status == store.PostStatusPublished {
+		app.postPublished(ctx, post)
+	}
 
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -76,20 +142,51 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			postID	path		int	true	"Post ID"
-//	@Success		200		{object}	store.Post
-//	@Failure		404		{object}	error	"post not found"
-//	@Failure		500		{object}	error	"internal server error"
+//	@Param			postID			path		int		true	"Post ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	store.Post
+//	@Success		304				{string}	string	"Not modified"
+//	@Failure		404				{object}	error	"post not found"
+//	@Failure		500				{object}	error	"internal server error"
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{postID} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	viewerID := getViewerID(r)
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID, viewerID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 	post.Comments = comments
+	bookmarked, err := app.store.Bookmarks.IsBookmarked(r.Context(), viewerID, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Bookmarked = bookmarked
+	if err := app.store.Posts.LoadEmbedded(r.Context(), viewerID, []*store.Post{post}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	media, err := app.store.Media.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Media = media
+
+	body, err := json.Marshal(post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	etag := representationETag(post, body)
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagsMatch(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -99,28 +196,36 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // deletePost godoc
 //
 //	@Summary		deletes post
-//	@Description	delete a post by ID
+//	@Description	Moves a post to its author's trash, from where it can be restored until it is purged
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			postID	path		int		true	"Post ID"
-//	@Success		204		{string}	string	"Post deleted"
-//	@Failure		404		{object}	error	"post not found"
-//	@Failure		500		{object}	error	"internal server error"
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			If-Match	header		string	false	"ETag of the version being deleted"
+//	@Success		204			{string}	string	"Post deleted"
+//	@Failure		403			{object}	error	"not the author"
+//	@Failure		404			{object}	error	"post not found"
+//	@Failure		409			{object}	error	"post changed concurrently"
+//	@Failure		412			{object}	error	"post changed since the If-Match version"
+//	@Failure		500			{object}	error	"internal server error"
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{postID} [delete]
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "postID")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	post := getPostFromCtx(r)
+	if post.USERID != getViewerID(r) {
+		app.forbiddenResponse(w, r, errNotPostAuthor)
+		return
+	}
+	if !app.checkIfMatch(w, r, post) {
 		return
 	}
 	ctx := r.Context()
-	if err = app.store.Posts.Delete(ctx, id); err != nil {
+	if err := app.store.Posts.Delete(ctx, post); err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrVersionConflict):
+			app.versionConflictResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -132,18 +237,33 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 // updatePost godoc
 //
 //	@Summary		update post
-//	@Description	update a post by ID
+//	@Description	update a post by ID. Drafts and scheduled posts can also be rescheduled, turned back into drafts or published through status and publish_at.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			postID	path		int	true	"Post ID"
-//	@Success		200		{object}	store.Post
-//	@Failure		404		{object}	error	"post not found"
-//	@Failure		500		{object}	error	"internal server error"
+//	@Param			postID		path		int					true	"Post ID"
+//	@Param			If-Match	header		string				false	"ETag of the version being edited"
+//	@Param			post		body		UpdatePostPayload	true	"Post payload"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error	"invalid payload"
+//	@Failure		403			{object}	error	"not the author"
+//	@Failure		404			{object}	error	"post not found"
+//	@Failure		409			{object}	error	"post changed since the given version"
+//	@Failure		412			{object}	error	"post changed since the If-Match version"
+//	@Failure		422			{object}	error	"rejected by the content filter"
+//	@Failure		500			{object}	error	"internal server error"
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{postID} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	if post.USERID != getViewerID(r) {
+		app.forbiddenResponse(w, r, errNotPostAuthor)
+		return
+	}
+	if post.RepostedPostID != nil {
+		app.statusBadRequest(w, r, errRepostNotEditable)
+		return
+	}
 
 	var payload UpdatePostPayload
 	if err := readJSON(w, r, &payload); err != nil {
@@ -156,17 +276,81 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !app.checkIfMatch(w, r, post) {
+		return
+	}
+	if payload.Version != nil {
+		if *payload.Version != post.Version {
+			app.versionConflictResponse(w, r, store.ErrVersionConflict)
+			return
+		}
+	}
+
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+	wasPublished := post.Status == store.PostStatusPublished
+	if payload.Status != nil || payload.PublishAt != nil {
+		if wasPublished && (payload.PublishAt != nil || *payload.Status != store.PostStatusPublished) {
+			app.statusBadRequest(w, r, errPostPublished)
+			return
+		}
+		if payload.Status != nil {
+			post.Status = *payload.Status
+			if post.Status != store.PostStatusScheduled {
+				post.PublishAt = nil
+			}
+		}
+		if payload.PublishAt != nil {
+			post.PublishAt = payload.PublishAt
+		}
+		if err := checkPublishAt(post); err != nil {
+			app.statusBadRequest(w, r, err)
+			return
+		}
+	}
+	if payload.Title != nil || payload.Content != nil {
+		screening, ok := app.screenContent(w, r, moderation.Content{
+			Kind:   store.ReportTargetPost,
+			UserID: post.USERID,
+			Edit:   true,
+			Fields: []moderation.Field{{Name: "title", Text: post.Title}, {Name: "content", Text: post.Content}},
+		})
+		if !ok {
+			return
+		}
+		post.FlagDetails = flagDetails(screening)
+	}
 
-	if err := app.store.Posts.Update(r.Context(), post); err != nil {
-		app.internalServerError(w, r, err)
+	ctx := r.Context()
+	if err := app.store.Posts.Update(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrVersionConflict):
+			app.versionConflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
+	w.Header().Set("ETag", postETag(post))
+	switch {
+	case !wasPublished && post.Status == store.PostStatusPublished:
+		app.postPublished(ctx, post)
+	case wasPublished:
+		topics := []string{}
+		for _, m := range post.Mentions {
+			topics = append(topics, userTopic(m.UserID))
+		}
+		app.publish(ctx, topics...)
+	}
 
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -174,6 +358,39 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// checkPublishAt validates the schedule of a post about to be saved with
+// post.Status.
+func checkPublishAt(post *store.Post) error {
+	switch {
+	case post.Status == store.PostStatusScheduled && post.PublishAt == nil:
+		return errPublishAtRequired
+	case post.Status == store.PostStatusScheduled && !post.PublishAt.After(time.Now()):
+		return errPublishAtPast
+	case post.Status != store.PostStatusScheduled && post.PublishAt != nil:
+		return errPublishAtUnscheduled
+	}
+	return nil
+}
+
+// postPublished wakes the streams of everyone a newly published post
+// concerns: the author's followers, the users it mentions and the author of
+// the post it quotes.
+func (app *application) postPublished(ctx context.Context, post *store.Post) {
+	if post.QuotedPostID != nil && post.QuotedPost == nil {
+		if err := app.store.Posts.LoadEmbedded(ctx, post.USERID, []*store.Post{post}); err != nil {
+			app.logger.Warnw("failed to load quoted post", "id", post.ID, "error", err)
+		}
+	}
+	topics := []string{authorTopic(post.USERID)}
+	for _, m := range post.Mentions {
+		topics = append(topics, userTopic(m.UserID))
+	}
+	if post.QuotedPost != nil {
+		topics = append(topics, userTopic(post.QuotedPost.UserID))
+	}
+	app.publish(ctx, topics...)
+}
+
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "postID")
@@ -193,6 +410,17 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+		// Posts the viewer may not see are reported as missing so their
+		// existence is not leaked.
+		ok, err := app.store.Posts.IsVisibleTo(ctx, post.ID, getViewerID(r))
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !ok {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
 		ctx = context.WithValue(ctx, PostCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+type CreateReportPayload struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetID   int64  `json:"target_id" validate:"required,gte=1"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate_speech violence nudity misinformation self_harm impersonation other"`
+	Details    string `json:"details" validate:"max=1000"`
+}
+
+// CreateReport godoc
+//
+//	@Summary		Reports content
+//	@Description	Reports a post, comment or user to the moderators. Posts and comments are hidden from everyone but their author once enough reports are open on them.
+//	@Tags			reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateReportPayload	true	"Report payload"
+//	@Success		201		{object}	store.Report
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"target not found"
+//	@Failure		409		{object}	error	"target already reported"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/reports [post]
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateReportPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	reporterID := getViewerID(r)
+	report := &store.Report{
+		ReporterID: &reporterID,
+		TargetType: payload.TargetType,
+		TargetID:   payload.TargetID,
+		Reason:     payload.Reason,
+		Details:    payload.Details,
+	}
+	if err := app.store.Reports.Create(r.Context(), report, app.config.moderation.reportHideThreshold); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrSelfReport):
+			app.statusBadRequest(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("you already reported this"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, report); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
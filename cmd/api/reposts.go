@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+// RepostPost godoc
+//
+//	@Summary		Reposts a post
+//	@Description	Shares a public post with the current user's followers. Reposting a repost shares the original post.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		201		{object}	store.Post
+//	@Failure		403		{object}	error	"post cannot be reposted"
+//	@Failure		404		{object}	error	"post not found"
+//	@Failure		409		{object}	error	"already reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [put]
+func (app *application) repostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	viewerID := getViewerID(r)
+	ctx := r.Context()
+
+	repost, err := app.store.Posts.Repost(ctx, viewerID, post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrNotShareable):
+			app.forbiddenResponse(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.store.Posts.LoadEmbedded(ctx, viewerID, []*store.Post{repost}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	topics := []string{authorTopic(viewerID)}
+	if repost.RepostedPost != nil {
+		topics = append(topics, userTopic(repost.RepostedPost.UserID))
+	}
+	app.publish(ctx, topics...)
+
+	if err := app.jsonResponse(w, http.StatusCreated, repost); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UnrepostPost godoc
+//
+//	@Summary		Removes a repost
+//	@Description	Removes the current user's repost of a post
+//	@Tags			posts
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Repost removed"
+//	@Failure		404		{object}	error	"post not found or not reposted"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/repost [delete]
+func (app *application) unrepostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if err := app.store.Posts.Unrepost(r.Context(), getViewerID(r), post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/Chandan185/Societal/internal/textdiff"
+)
+
+var errMissingFromVersion = errors.New("from is required")
+
+type RevisionDiff struct {
+	PostID     int64         `json:"post_id"`
+	From       int64         `json:"from"`
+	To         int64         `json:"to"`
+	Title      []textdiff.Op `json:"title"`
+	Content    []textdiff.Op `json:"content"`
+	Visibility []textdiff.Op `json:"visibility"`
+}
+
+// GetPostRevisions godoc
+//
+//	@Summary		Lists post revisions
+//	@Description	Lists every version of a post, newest first. The first entry is the current version.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.PostRevision
+//	@Failure		404		{object}	error	"post not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	revisions, err := app.store.Posts.GetRevisions(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DiffPostRevisions godoc
+//
+//	@Summary		Compares post revisions
+//	@Description	Returns a word-level diff of the title, content and visibility of a post between two versions
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			from	query		int	true	"Old version"
+//	@Param			to		query		int	false	"New version, the current one by default"
+//	@Success		200		{object}	RevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"post or version not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) diffPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	qs := r.URL.Query()
+	if qs.Get("from") == "" {
+		app.statusBadRequest(w, r, errMissingFromVersion)
+		return
+	}
+	from, err := strconv.ParseInt(qs.Get("from"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	to := post.Version
+	if v := qs.Get("to"); v != "" {
+		to, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			app.statusBadRequest(w, r, err)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	var revisions [2]*store.PostRevision
+	for i, version := range []int64{from, to} {
+		revisions[i], err = app.store.Posts.GetRevision(ctx, post.ID, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	older, newer := revisions[0], revisions[1]
+	diff := RevisionDiff{
+		PostID:     post.ID,
+		From:       from,
+		To:         to,
+		Title:      textdiff.Diff(older.Title, newer.Title),
+		Content:    textdiff.Diff(older.Content, newer.Content),
+		Visibility: textdiff.Diff(older.Visibility, newer.Visibility),
+	}
+	if err := app.jsonResponse(w, http.StatusOK, diff); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+const (
+	// streamHeartbeat is how often an idle stream sends a comment line so
+	// proxies and clients can tell the connection is still alive.
+	streamHeartbeat = 15 * time.Second
+	// streamWriteTimeout bounds each write to a stream. A client that
+	// cannot keep up is disconnected and resumes with Last-Event-ID
+	// instead of holding events in server memory.
+	streamWriteTimeout = 10 * time.Second
+	// streamBatchSize is how many events of each kind are read per wake-up.
+	streamBatchSize = 50
+	// maxWatchedPosts caps how many posts one stream follows comments on.
+	maxWatchedPosts = 20
+	// streamRetry is the reconnect delay, in milliseconds, suggested to
+	// clients.
+	streamRetry = 5000
+)
+
+var (
+	errInvalidEventID = errors.New("invalid Last-Event-ID")
+	errTooManyPosts   = fmt.Errorf("at most %d posts can be watched", maxWatchedPosts)
+)
+
+func userTopic(id int64) string   { return fmt.Sprintf("user:%d", id) }
+func authorTopic(id int64) string { return fmt.Sprintf("author:%d", id) }
+func postTopic(id int64) string   { return fmt.Sprintf("post:%d", id) }
+
+// publish wakes up the streams subscribed to topics. Failures only delay
+// delivery until the client's next reconnect, so they are logged rather than
+// failing the request that caused them.
+func (app *application) publish(ctx context.Context, topics ...string) {
+	if len(topics) == 0 {
+		return
+	}
+	if err := app.broker.Publish(ctx, topics...); err != nil {
+		app.logger.Warnw("failed to publish stream event", "topics", topics, "error", err)
+	}
+}
+
+// streamCursor is the position of a stream in each kind of event. It is sent
+// as the ID of every event so a reconnecting client resumes where it left
+// off.
+type streamCursor struct {
+	// Post is placed by when posts were published rather than created, so
+	// scheduled posts are streamed when they are published.
+	Post         store.Position
+	Notification store.Position
+	Comment      store.Position
+}
+
+func (c streamCursor) String() string {
+	return fmt.Sprintf("p%d.%d-n%d.%d-c%d.%d", c.Post.TxID, c.Post.ID, c.Notification.TxID, c.Notification.ID, c.Comment.TxID, c.Comment.ID)
+}
+
+// parseStreamCursor parses an event ID. IDs handed out before streams were
+// ordered by commit hold only row IDs, which resume from transaction 0.
+func parseStreamCursor(s string) (streamCursor, error) {
+	var c streamCursor
+	if _, err := fmt.Sscanf(s, "p%d.%d-n%d.%d-c%d.%d", &c.Post.TxID, &c.Post.ID, &c.Notification.TxID, &c.Notification.ID, &c.Comment.TxID, &c.Comment.ID); err != nil {
+		c = streamCursor{}
+		if _, err := fmt.Sscanf(s, "p%d-n%d-c%d", &c.Post.ID, &c.Notification.ID, &c.Comment.ID); err != nil {
+			return c, errInvalidEventID
+		}
+	}
+	for _, pos := range []store.Position{c.Post, c.Notification, c.Comment} {
+		if pos.TxID < 0 || pos.ID < 0 {
+			return c, errInvalidEventID
+		}
+	}
+	return c, nil
+}
+
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// send writes one event and flushes it to the client.
+func (s *sseWriter) send(event string, cursor streamCursor, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", cursor, event, b))
+}
+
+func (s *sseWriter) write(msg string) error {
+	if err := s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := fmt.Fprint(s.w, msg); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// stream godoc
+//
+//	@Summary		Streams live events
+//	@Description	Opens a Server-Sent Events stream of new posts in the current user's feed ("post"), their notifications ("notification") and new comments on the watched posts ("comment"). Reconnect with the Last-Event-ID header, or lastEventId query parameter, to receive everything missed since that event.
+//	@Tags			stream
+//	@Produce		text/event-stream
+//	@Param			posts		query		string	false	"Comma separated IDs of posts to watch for comments"
+//	@Param			lastEventId	query		string	false	"Resume after this event ID"
+//	@Success		200			{string}	string	"Event stream"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"post not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := longLivedContext(r)
+	defer cancel()
+	viewerID := getViewerID(r)
+
+	postIDs, err := parseWatchedPosts(r.URL.Query().Get("posts"))
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	for _, id := range postIDs {
+		visible, err := app.store.Posts.IsVisibleTo(ctx, id, viewerID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+	}
+
+	cursor, err := app.initialStreamCursor(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidEventID):
+			app.statusBadRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	following, err := app.store.Followers.GetFollowingIDs(ctx, viewerID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	topics := []string{userTopic(viewerID), authorTopic(viewerID)}
+	for _, id := range following {
+		topics = append(topics, authorTopic(id))
+	}
+	for _, id := range postIDs {
+		topics = append(topics, postTopic(id))
+	}
+	sub := app.broker.Subscribe(topics...)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sse := &sseWriter{w: w, rc: rc}
+	if err := sse.write(fmt.Sprintf("retry: %d\n\n", streamRetry)); err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			app.logger.Errorw("response writer does not support streaming", "path", r.URL.Path)
+		}
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// Catch up on anything since the client's cursor before waiting.
+	more := true
+	for {
+		if more {
+			more, err = app.sendStreamEvents(ctx, sse, viewerID, postIDs, &cursor)
+			if err != nil {
+				if ctx.Err() == nil {
+					app.logger.Warnw("stream closed", "error", err, "user", viewerID)
+				}
+				return
+			}
+			if more {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.C:
+			more = true
+		case <-heartbeat.C:
+			if err := sse.write(": ping\n\n"); err != nil {
+				return
+			}
+			// Events held back behind a transaction that was still
+			// running have no wake-up of their own.
+			more = true
+		}
+	}
+}
+
+// initialStreamCursor resumes from the client's last event ID, or starts
+// from what is committed now so a fresh stream only carries what happens
+// next.
+func (app *application) initialStreamCursor(r *http.Request) (streamCursor, error) {
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	if lastID != "" {
+		return parseStreamCursor(lastID)
+	}
+
+	start, err := app.store.Streams.Start(r.Context())
+	if err != nil {
+		return streamCursor{}, err
+	}
+	return streamCursor{Post: start, Notification: start, Comment: start}, nil
+}
+
+// sendStreamEvents sends every kind of event newer than cursor, up to one
+// batch of each, advancing cursor as it goes. It reports whether any batch
+// was full and more events may be waiting.
+func (app *application) sendStreamEvents(ctx context.Context, sse *sseWriter, viewerID int64, postIDs []int64, cursor *streamCursor) (bool, error) {
+	more := false
+
+	posts, err := app.store.Posts.GetFeedSince(ctx, viewerID, cursor.Post, streamBatchSize)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range posts {
+		cursor.Post = store.Position{TxID: p.PublishedTxID, ID: p.PublishedSeq}
+		if err := sse.send("post", *cursor, p); err != nil {
+			return false, err
+		}
+	}
+	more = more || len(posts) == streamBatchSize
+
+	notifications, err := app.store.Notifications.GetSince(ctx, viewerID, cursor.Notification, streamBatchSize)
+	if err != nil {
+		return false, err
+	}
+	for _, n := range notifications {
+		cursor.Notification = store.Position{TxID: n.TxID, ID: n.ID}
+		if err := sse.send("notification", *cursor, n); err != nil {
+			return false, err
+		}
+	}
+	more = more || len(notifications) == streamBatchSize
+
+	if len(postIDs) > 0 {
+		comments, err := app.store.Comments.GetSince(ctx, postIDs, viewerID, cursor.Comment, streamBatchSize)
+		if err != nil {
+			return false, err
+		}
+		for _, c := range comments {
+			cursor.Comment = store.Position{TxID: c.TxID, ID: c.ID}
+			if err := sse.send("comment", *cursor, c); err != nil {
+				return false, err
+			}
+		}
+		more = more || len(comments) == streamBatchSize
+	}
+	return more, nil
+}
+
+func parseWatchedPosts(s string) ([]int64, error) {
+	ids := []int64{}
+	if s == "" {
+		return ids, nil
+	}
+	seen := map[int64]bool{}
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) > maxWatchedPosts {
+		return nil, errTooManyPosts
+	}
+	return ids, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errAccountSuspended   = errors.New("account suspended")
+	errAccountDeactivated = errors.New("account deactivated")
+)
+
+// activeViewerMiddleware turns suspended and deactivated users away. There
+// is no authentication yet, so it checks the user getViewerID resolves to.
+func (app *application) activeViewerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, err := app.store.Suspensions.GetAccountStatus(r.Context(), getViewerID(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				next.ServeHTTP(w, r)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		if status.Deactivated {
+			app.forbiddenResponse(w, r, errors.New("your account is deactivated"))
+			return
+		}
+		suspension := status.Suspension
+		if suspension == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		msg := "your account is suspended"
+		if suspension.ExpiresAt != nil {
+			msg += " until " + suspension.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		app.forbiddenResponse(w, r, fmt.Errorf("%s: %s", msg, suspension.Reason))
+	})
+}
+
+type SuspendUserPayload struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+	// Days is how long the suspension lasts. Leaving it out bans the user
+	// until the suspension is lifted.
+	Days int `json:"days" validate:"omitempty,gte=1,lte=3650"`
+}
+
+// SuspendUser godoc
+//
+//	@Summary		Suspends a user
+//	@Description	Suspends a user for a number of days, or indefinitely. Suspended users cannot use the API, their profiles are gone and their posts are left out of feeds. Only admins may use it.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		SuspendUserPayload	true	"Suspension"
+//	@Success		201		{object}	store.Suspension
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not found"
+//	@Failure		409		{object}	error	"user already suspended"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspension [post]
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload SuspendUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	issuedBy := getViewerID(r)
+	if userID == issuedBy {
+		app.statusBadRequest(w, r, errSelfTarget)
+		return
+	}
+
+	suspension := &store.Suspension{
+		UserID:   userID,
+		Reason:   payload.Reason,
+		IssuedBy: &issuedBy,
+	}
+	if payload.Days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, payload.Days)
+		suspension.ExpiresAt = &expiresAt
+	}
+	if err := app.store.Suspensions.Create(r.Context(), suspension); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrAlreadySuspended):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, suspension); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// LiftSuspension godoc
+//
+//	@Summary		Lifts a suspension
+//	@Description	Ends the suspension in force on a user early
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.Suspension
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"user not suspended"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/suspension [delete]
+func (app *application) liftSuspensionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	liftedBy := getViewerID(r)
+	suspension, err := app.store.Suspensions.Lift(r.Context(), userID, &liftedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotSuspended):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, suspension); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetSuspensions godoc
+//
+//	@Summary		Lists suspensions
+//	@Description	Lists suspensions, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			user_id	query		int		false	"Only suspensions of this user"
+//	@Param			active	query		bool	false	"Only suspensions in force"
+//	@Success		200		{object}	[]store.Suspension
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/suspensions [get]
+func (app *application) getSuspensionsHandler(w http.ResponseWriter, r *http.Request) {
+	sq := store.SuspensionQuery{
+		Limit: 20,
+	}
+	sq, err := sq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(sq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	suspensions, err := app.store.Suspensions.List(r.Context(), sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, suspensions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetTrash godoc
+//
+//	@Summary		Lists deleted posts
+//	@Description	Lists the current user's deleted posts, most recently deleted first. Each post can be restored until its purge_at time.
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.TrashedPost
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/trash [get]
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	tq := store.TrashQuery{
+		Limit: 20,
+	}
+	tq, err := tq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(tq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	trash, err := app.store.Posts.GetTrash(r.Context(), getViewerID(r), tq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range trash {
+		trash[i].PurgeAt = trash[i].DeletedAt.Add(app.config.trash.retention)
+	}
+	if err := app.jsonResponse(w, http.StatusOK, trash); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RestorePost godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Takes one of the current user's posts out of the trash
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"post not found in trash"
+//	@Failure		409		{object}	error	"post was reposted again since"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/restore [post]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	viewerID := getViewerID(r)
+	ctx := r.Context()
+
+	if err := app.store.Posts.Restore(ctx, viewerID, postID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post, err := app.store.Posts.GetByID(ctx, postID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.Posts.LoadEmbedded(ctx, viewerID, []*store.Post{post}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Media, err = app.store.Media.GetByPostID(ctx, post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -21,13 +22,126 @@ var UserContextKey userKey = "user"
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	store.PublicProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
+//	@Failure		410	{object}	error	"account suspended"
 //	@Failure		500	{object}	error
 //	@Router			/users/{id} [get]
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromCtx(r)
+	var data any = user.PublicProfile()
+	if user.ID == getViewerID(r) {
+		data = user
+	}
+	if err := app.jsonResponse(w, http.StatusOK, data); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+var errEditConflict = errors.New("the resource was modified concurrently, please retry")
+
+type UpdateProfilePayload struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=100"`
+	Bio         *string `json:"bio" validate:"omitempty,max=500"`
+	Website     *string `json:"website" validate:"omitempty,url,max=255"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,url,max=255"`
+}
+
+// GetMe godoc
+//
+//	@Summary		Fetches the current user
+//	@Description	Fetches the full profile of the current user, including private fields
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.User
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getMeHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.store.Users.GetByID(r.Context(), getViewerID(r))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateProfile godoc
+//
+//	@Summary		Updates the current user's profile
+//	@Description	Updates display name, bio, website, location and avatar of the current user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayload	true	"Profile payload"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"edit conflict"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByID(ctx, getViewerID(r))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+
+	if err := app.store.Users.Update(ctx, user); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, errEditConflict)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -45,10 +159,12 @@ type FollowUser struct {
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userid	path		int		true	"User ID"
-//	@Success		204		{string}	string	"User followed"
-//	@Failure		404		{object}	error	"user payload invalid"
-//	@Failure		400		{object}	error	"user not found"
+//	@Param			userid	path		int					true	"User ID"
+//	@Success		204		{string}	string				"User followed"
+//	@Success		202		{object}	store.FollowRequest	"Follow request sent to a private account"
+//	@Failure		404		{object}	error				"user payload invalid"
+//	@Failure		400		{object}	error				"user not found"
+//	@Failure		403		{object}	error				"user is blocked"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userId}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -59,16 +175,36 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		app.statusBadRequest(w, r, err)
 		return
 	}
-	if err := app.store.Followers.Follow(r.Context(), followerUser.ID, payload.UserID); err != nil {
+	ctx := r.Context()
+	followedUser, err := app.store.Users.GetByID(ctx, payload.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if followedUser.IsPrivate {
+		app.requestFollow(w, r, followerUser.ID, followedUser.ID)
+		return
+	}
+	if err := app.store.Followers.Follow(ctx, followerUser.ID, payload.UserID); err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.conflictResponse(w, r, err)
 			return
+		case store.ErrBlocked:
+			app.forbiddenResponse(w, r, err)
+			return
 		default:
 			app.internalServerError(w, r, err)
 			return
 		}
 	}
+	app.publish(ctx, userTopic(payload.UserID))
+
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -106,6 +242,8 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// userContextMiddleware loads the user named in the URL. Suspended and
+// deactivated users are gone as far as everyone else is concerned.
 func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userId, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
@@ -124,6 +262,19 @@ func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 				return
 			}
 		}
+		if user.DeactivatedAt != nil {
+			app.suspendedResponse(w, r, errAccountDeactivated)
+			return
+		}
+		_, err = app.store.Suspensions.GetActive(r.Context(), userId)
+		switch {
+		case err == nil:
+			app.suspendedResponse(w, r, errAccountSuspended)
+			return
+		case !errors.Is(err, store.ErrNotFound):
+			app.internalServerError(w, r, err)
+			return
+		}
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, UserContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -137,3 +288,12 @@ func getUserFromCtx(r *http.Request) *store.User {
 	}
 	return user
 }
+
+// viewerID is the user every request acts on behalf of. There is no
+// authentication yet, so handlers resolve the acting user through
+// getViewerID rather than hard-coding it.
+const viewerID int64 = 1
+
+func getViewerID(r *http.Request) int64 {
+	return viewerID
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"github.com/Chandan185/Societal/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+)
+
+var errWebhookScheme = errors.New("webhook url must use http or https")
+
+type CreateWebhookPayload struct {
+	URL string `json:"url" validate:"required,url,max=2048"`
+	// Secret signs deliveries. One is generated when it is left out.
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=256"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=post.created post.published post.updated post.deleted post.restored post.purged comment.created user.followed user.unfollowed"`
+}
+
+type UpdateWebhookPayload struct {
+	URL        *string   `json:"url" validate:"omitempty,url,max=2048"`
+	Secret     *string   `json:"secret" validate:"omitempty,min=16,max=256"`
+	EventTypes *[]string `json:"event_types" validate:"omitempty,min=1,dive,oneof=post.created post.published post.updated post.deleted post.restored post.purged comment.created user.followed user.unfollowed"`
+	// Active re-enables a webhook that was disabled after repeated failures,
+	// or pauses one.
+	Active *bool `json:"active"`
+}
+
+func checkWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errWebhookScheme
+	}
+	return webhooks.CheckHost(u.Hostname())
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateWebhook godoc
+//
+//	@Summary		Creates a webhook
+//	@Description	Subscribes a URL to events concerning the current user. Each delivery is a JSON POST signed with HMAC-SHA256: X-Societal-Signature is "sha256=" and the hex HMAC under the secret of the X-Societal-Timestamp value, a dot and the body. The secret is only returned here.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateWebhookPayload	true	"Webhook payload"
+//	@Success		201		{object}	store.Webhook
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks [post]
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := checkWebhookURL(payload.URL); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	webhook := &store.Webhook{
+		UserID:     getViewerID(r),
+		URL:        payload.URL,
+		Secret:     payload.Secret,
+		EventTypes: payload.EventTypes,
+	}
+	if webhook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		webhook.Secret = secret
+	}
+	if err := app.store.Webhooks.Create(r.Context(), webhook); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, webhook); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetWebhooks godoc
+//
+//	@Summary		Lists webhooks
+//	@Description	Lists the current user's webhooks
+//	@Tags			webhooks
+//	@Produce		json
+//	@Success		200	{object}	[]store.Webhook
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks [get]
+func (app *application) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.store.Webhooks.GetByUserID(r.Context(), getViewerID(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, webhooks); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetWebhook godoc
+//
+//	@Summary		Fetches a webhook
+//	@Description	Fetches one of the current user's webhooks
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookID	path		int	true	"Webhook ID"
+//	@Success		200			{object}	store.Webhook
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"webhook not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{webhookID} [get]
+func (app *application) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	webhook, err := app.store.Webhooks.GetByID(r.Context(), getViewerID(r), webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, webhook); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateWebhook godoc
+//
+//	@Summary		Updates a webhook
+//	@Description	Changes the URL, secret or events of one of the current user's webhooks, or enables or disables it. Enabling a webhook starts its count of failures over.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			webhookID	path		int						true	"Webhook ID"
+//	@Param			payload		body		UpdateWebhookPayload	true	"Webhook payload"
+//	@Success		200			{object}	store.Webhook
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"webhook not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{webhookID} [patch]
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	var payload UpdateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(payload); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	webhook, err := app.store.Webhooks.GetByID(ctx, getViewerID(r), webhookID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if payload.URL != nil {
+		if err := checkWebhookURL(*payload.URL); err != nil {
+			app.statusBadRequest(w, r, err)
+			return
+		}
+		webhook.URL = *payload.URL
+	}
+	if payload.Secret != nil {
+		webhook.Secret = *payload.Secret
+	}
+	if payload.EventTypes != nil {
+		webhook.EventTypes = *payload.EventTypes
+	}
+	if payload.Active != nil {
+		webhook.Active = *payload.Active
+	}
+
+	if err := app.store.Webhooks.Update(ctx, webhook); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, webhook); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DeleteWebhook godoc
+//
+//	@Summary		Deletes a webhook
+//	@Description	Deletes one of the current user's webhooks along with its deliveries
+//	@Tags			webhooks
+//	@Param			webhookID	path		int		true	"Webhook ID"
+//	@Success		204			{string}	string	"Webhook deleted"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"webhook not found"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{webhookID} [delete]
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := app.store.Webhooks.Delete(r.Context(), getViewerID(r), webhookID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+//
+//	@Summary		Lists webhook deliveries
+//	@Description	Lists the deliveries of one of the current user's webhooks, newest first, with the history of attempts at each
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			webhookID	path		int		true	"Webhook ID"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			offset		query		int		false	"Offset"
+//	@Param			status		query		string	false	"Only deliveries with this status: pending, succeeded or failed"
+//	@Success		200			{object}	[]store.WebhookDelivery
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{webhookID}/deliveries [get]
+func (app *application) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	wq := store.WebhookDeliveryQuery{
+		Limit: 20,
+	}
+	wq, err = wq.Parse(r)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(wq); err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+
+	deliveries, err := app.store.Webhooks.GetDeliveries(r.Context(), getViewerID(r), webhookID, wq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, deliveries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RedeliverWebhook godoc
+//
+//	@Summary		Redelivers a webhook delivery
+//	@Description	Sends a succeeded or failed delivery again with the original body and a fresh set of attempts
+//	@Tags			webhooks
+//	@Param			webhookID	path		int		true	"Webhook ID"
+//	@Param			deliveryID	path		int		true	"Delivery ID"
+//	@Success		202			{string}	string	"Delivery queued"
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"delivery not found"
+//	@Failure		409			{object}	error	"delivery still pending or webhook disabled"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver [post]
+func (app *application) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		app.statusBadRequest(w, r, err)
+		return
+	}
+	if err := app.store.Webhooks.Redeliver(r.Context(), getViewerID(r), webhookID, deliveryID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrDeliveryPending), errors.Is(err, store.ErrWebhookDisabled):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.jobs.Notify()
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mentions;
+
+ALTER TABLE posts
+DROP COLUMN visibility;
\ No newline at end of file
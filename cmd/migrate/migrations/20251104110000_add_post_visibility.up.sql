@@ -0,0 +1,15 @@
+ALTER TABLE posts
+ADD COLUMN visibility varchar(16) NOT NULL DEFAULT 'public'
+CHECK (visibility IN ('public', 'followers', 'mentioned'));
+
+CREATE TABLE IF NOT EXISTS mentions (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions (user_id);
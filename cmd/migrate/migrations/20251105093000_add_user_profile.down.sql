@@ -0,0 +1,8 @@
+ALTER TABLE users
+DROP COLUMN display_name,
+DROP COLUMN bio,
+DROP COLUMN website,
+DROP COLUMN location,
+DROP COLUMN avatar_url,
+DROP COLUMN version,
+DROP COLUMN updated_at;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    post_id bigint,
+    position int NOT NULL DEFAULT 0,
+    storage_key text NOT NULL UNIQUE,
+    content_type varchar(100) NOT NULL,
+    size_bytes bigint NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_post_id ON media (post_id);
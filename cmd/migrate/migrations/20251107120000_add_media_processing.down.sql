@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS media_variants;
+DROP INDEX IF EXISTS idx_media_unprocessed;
+
+ALTER TABLE media
+DROP COLUMN status,
+DROP COLUMN width,
+DROP COLUMN height,
+DROP COLUMN placeholder,
+DROP COLUMN processing_started_at;
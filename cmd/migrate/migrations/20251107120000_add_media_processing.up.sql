@@ -0,0 +1,24 @@
+ALTER TABLE media
+ADD COLUMN status varchar(16) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'processing', 'ready', 'failed')),
+ADD COLUMN width int NOT NULL DEFAULT 0,
+ADD COLUMN height int NOT NULL DEFAULT 0,
+ADD COLUMN placeholder varchar(64) NOT NULL DEFAULT '',
+ADD COLUMN processing_started_at timestamp(0) with time zone;
+
+-- Only images go through the processor; anything else is ready as uploaded.
+UPDATE media SET status = 'ready' WHERE content_type NOT LIKE 'image/%';
+
+CREATE INDEX IF NOT EXISTS idx_media_unprocessed ON media (id) WHERE status IN ('pending', 'processing');
+
+CREATE TABLE IF NOT EXISTS media_variants (
+    media_id bigint NOT NULL,
+    name varchar(16) NOT NULL,
+    storage_key text NOT NULL UNIQUE,
+    content_type varchar(100) NOT NULL,
+    width int NOT NULL,
+    height int NOT NULL,
+    size_bytes bigint NOT NULL,
+    PRIMARY KEY (media_id, name),
+    FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
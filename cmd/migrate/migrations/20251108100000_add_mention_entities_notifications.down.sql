@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS notifications;
+
+DROP INDEX IF EXISTS idx_mentions_comment_id;
+DROP INDEX IF EXISTS idx_mentions_post_id;
+
+DELETE FROM mentions WHERE comment_id IS NOT NULL;
+DELETE FROM mentions a USING mentions b
+WHERE a.post_id = b.post_id AND a.user_id = b.user_id AND a.id > b.id;
+
+ALTER TABLE mentions
+DROP CONSTRAINT fk_mentions_comment,
+DROP COLUMN comment_id,
+DROP COLUMN start_offset,
+DROP COLUMN end_offset,
+ADD CONSTRAINT mentions_post_id_user_id_key UNIQUE (post_id, user_id);
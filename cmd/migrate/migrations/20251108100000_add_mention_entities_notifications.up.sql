@@ -0,0 +1,26 @@
+ALTER TABLE mentions
+DROP CONSTRAINT IF EXISTS mentions_post_id_user_id_key,
+ADD COLUMN comment_id bigint,
+ADD COLUMN start_offset int NOT NULL DEFAULT 0,
+ADD COLUMN end_offset int NOT NULL DEFAULT 0,
+ADD CONSTRAINT fk_mentions_comment FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_mentions_post_id ON mentions (post_id);
+CREATE INDEX IF NOT EXISTS idx_mentions_comment_id ON mentions (comment_id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    actor_id bigint NOT NULL,
+    type varchar(32) NOT NULL,
+    post_id bigint,
+    comment_id bigint,
+    read_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, id DESC);
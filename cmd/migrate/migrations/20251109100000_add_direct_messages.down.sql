@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversation_members;
+DROP SEQUENCE IF EXISTS conversation_receipt_seq;
+DROP TABLE IF EXISTS conversations;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS conversations (
+    id bigserial PRIMARY KEY,
+    user_low_id bigint NOT NULL,
+    user_high_id bigint NOT NULL,
+    last_message_id bigint NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_low_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_high_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_low_id, user_high_id),
+    CHECK (user_low_id < user_high_id)
+);
+
+CREATE SEQUENCE IF NOT EXISTS conversation_receipt_seq;
+
+CREATE TABLE IF NOT EXISTS conversation_members (
+    conversation_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    last_delivered_id bigint NOT NULL DEFAULT 0,
+    last_read_id bigint NOT NULL DEFAULT 0,
+    receipt_seq bigint NOT NULL DEFAULT nextval('conversation_receipt_seq'),
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members (user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id bigserial PRIMARY KEY,
+    conversation_id bigint NOT NULL,
+    sender_id bigint NOT NULL,
+    content text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages (conversation_id, id DESC);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_posts_quoted_post_id;
+DROP INDEX IF EXISTS idx_posts_reposted_post_id;
+DROP INDEX IF EXISTS idx_posts_user_id_reposted_post_id;
+
+DELETE FROM posts WHERE reposted_post_id IS NOT NULL;
+
+ALTER TABLE posts
+DROP CONSTRAINT IF EXISTS fk_posts_quoted_post,
+DROP CONSTRAINT IF EXISTS fk_posts_reposted_post,
+DROP COLUMN IF EXISTS quoted_post_id,
+DROP COLUMN IF EXISTS reposted_post_id;
@@ -0,0 +1,9 @@
+ALTER TABLE posts
+ADD COLUMN reposted_post_id bigint,
+ADD COLUMN quoted_post_id bigint,
+ADD CONSTRAINT fk_posts_reposted_post FOREIGN KEY (reposted_post_id) REFERENCES posts(id) ON DELETE CASCADE,
+ADD CONSTRAINT fk_posts_quoted_post FOREIGN KEY (quoted_post_id) REFERENCES posts(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_reposted_post_id ON posts (user_id, reposted_post_id) WHERE reposted_post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_reposted_post_id ON posts (reposted_post_id) WHERE reposted_post_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_quoted_post_id ON posts (quoted_post_id) WHERE quoted_post_id IS NOT NULL;
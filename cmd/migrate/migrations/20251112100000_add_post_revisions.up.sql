@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL,
+    version int NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    visibility varchar(16) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL,
+    replaced_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    UNIQUE (post_id, version)
+);
@@ -0,0 +1,12 @@
+ALTER TABLE comments
+DROP CONSTRAINT IF EXISTS fk_comments_post;
+
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_posts_user_id_reposted_post_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_reposted_post_id ON posts (user_id, reposted_post_id) WHERE reposted_post_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS deleted_at;
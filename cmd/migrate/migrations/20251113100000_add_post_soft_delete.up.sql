@@ -0,0 +1,14 @@
+ALTER TABLE posts
+ADD COLUMN deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- A repost in the trash must not stop the user from reposting again.
+DROP INDEX IF EXISTS idx_posts_user_id_reposted_post_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_user_id_reposted_post_id ON posts (user_id, reposted_post_id) WHERE reposted_post_id IS NOT NULL AND deleted_at IS NULL;
+
+-- Comments on posts that were already hard-deleted can never be reached.
+DELETE FROM comments c WHERE NOT EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE;
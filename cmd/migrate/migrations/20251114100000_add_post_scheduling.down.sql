@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_posts_unpublished;
+DROP INDEX IF EXISTS idx_posts_due;
+DROP INDEX IF EXISTS idx_posts_published_seq;
+
+DELETE FROM posts WHERE status <> 'published';
+
+ALTER TABLE posts
+DROP CONSTRAINT IF EXISTS posts_scheduled_publish_at,
+DROP COLUMN IF EXISTS published_seq,
+DROP COLUMN IF EXISTS publish_at,
+DROP COLUMN IF EXISTS status;
+
+DROP SEQUENCE IF EXISTS post_publish_seq;
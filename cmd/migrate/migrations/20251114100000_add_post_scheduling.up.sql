@@ -0,0 +1,18 @@
+ALTER TABLE posts
+ADD COLUMN status varchar(16) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD COLUMN publish_at timestamp(0) with time zone,
+ADD COLUMN published_seq bigint,
+ADD CONSTRAINT posts_scheduled_publish_at CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+-- published_seq orders posts by when they were published, which for
+-- scheduled posts is later than their ID suggests. Existing posts keep their
+-- ID so stream cursors handed out before this migration stay valid.
+CREATE SEQUENCE IF NOT EXISTS post_publish_seq;
+UPDATE posts SET published_seq = id;
+SELECT setval('post_publish_seq', COALESCE((SELECT MAX(id) FROM posts), 0) + 1, false);
+ALTER TABLE posts ALTER COLUMN published_seq SET DEFAULT nextval('post_publish_seq');
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_published_seq ON posts (published_seq);
+CREATE INDEX IF NOT EXISTS idx_posts_due ON posts (publish_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_posts_unpublished ON posts (user_id) WHERE status <> 'published';
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id bigserial PRIMARY KEY,
+    kind varchar(64) NOT NULL,
+    payload jsonb NOT NULL DEFAULT '{}',
+    status varchar(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'dead')),
+    attempts int NOT NULL DEFAULT 0,
+    max_attempts int NOT NULL,
+    unique_key text,
+    run_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    locked_at timestamp(0) with time zone,
+    last_error text,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_runnable ON jobs (run_at, id) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_jobs_dead ON jobs (id) WHERE status = 'dead';
+
+-- A unique key only blocks duplicates while the job is still to be done.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_unique_key ON jobs (kind, unique_key)
+    WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
@@ -0,0 +1,20 @@
+-- Events are ordered by the transaction that recorded them. The relay only
+-- reads events of transactions older than every transaction still running,
+-- so an event can never show up behind a checkpoint that already passed it.
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    txid bigint NOT NULL DEFAULT txid_current(),
+    type varchar(64) NOT NULL,
+    payload jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_position ON outbox (txid, id);
+CREATE INDEX IF NOT EXISTS idx_outbox_created_at ON outbox (created_at);
+
+CREATE TABLE IF NOT EXISTS outbox_checkpoints (
+    subscriber varchar(64) PRIMARY KEY,
+    last_txid bigint NOT NULL,
+    last_event_id bigint NOT NULL,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS suspensions;
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS reports;
+ALTER TABLE comments DROP COLUMN IF EXISTS hidden_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS hidden_at;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,67 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS role varchar(16) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- Hidden content stays visible to its author only.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id bigserial PRIMARY KEY,
+    reporter_id bigint NOT NULL,
+    target_type varchar(16) NOT NULL CHECK (target_type IN ('post', 'comment', 'user')),
+    target_id bigint NOT NULL,
+    -- subject_id is the author of the reported content, or the reported user.
+    subject_id bigint NOT NULL,
+    reason varchar(32) NOT NULL,
+    details text NOT NULL DEFAULT '',
+    status varchar(16) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    assignee_id bigint,
+    resolved_by bigint,
+    resolved_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (subject_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (assignee_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- A user can only have one open report on the same target.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_reporter ON reports (reporter_id, target_type, target_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status, id);
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports (target_type, target_id);
+
+-- moderator_id is NULL for actions taken automatically.
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id bigserial PRIMARY KEY,
+    report_id bigint,
+    moderator_id bigint,
+    action varchar(16) NOT NULL CHECK (action IN ('dismiss', 'hide', 'warn', 'suspend')),
+    target_type varchar(16) NOT NULL,
+    target_id bigint NOT NULL,
+    subject_id bigint NOT NULL,
+    note text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (subject_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_report_id ON moderation_actions (report_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target ON moderation_actions (target_type, target_id);
+
+CREATE TABLE IF NOT EXISTS suspensions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    reason text NOT NULL,
+    issued_by bigint,
+    report_id bigint,
+    expires_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (issued_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_suspensions_user_id ON suspensions (user_id);
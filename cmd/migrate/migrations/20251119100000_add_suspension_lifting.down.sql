@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_suspensions_in_force;
+ALTER TABLE suspensions
+DROP COLUMN IF EXISTS lifted_by,
+DROP COLUMN IF EXISTS lifted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE suspensions
+ADD COLUMN IF NOT EXISTS lifted_at timestamp(0) with time zone,
+ADD COLUMN IF NOT EXISTS lifted_by bigint REFERENCES users(id) ON DELETE SET NULL;
+
+-- Suspensions in force are looked up on every request.
+CREATE INDEX IF NOT EXISTS idx_suspensions_in_force ON suspensions (user_id) WHERE lifted_at IS NULL;
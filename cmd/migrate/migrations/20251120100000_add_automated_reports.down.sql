@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_reports_open_automated;
+DELETE FROM reports WHERE reporter_id IS NULL;
+ALTER TABLE reports ALTER COLUMN reporter_id SET NOT NULL;
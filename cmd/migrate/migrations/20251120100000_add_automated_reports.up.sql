@@ -0,0 +1,5 @@
+-- Reports filed by the content filter have no reporter.
+ALTER TABLE reports ALTER COLUMN reporter_id DROP NOT NULL;
+
+-- Content has at most one open report from the filter.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_automated ON reports (target_type, target_id) WHERE status = 'open' AND reporter_id IS NULL;
@@ -0,0 +1,33 @@
+-- actor_id is NULL for changes made by background workers. It has no
+-- foreign key so entries outlive the users they mention.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id bigserial PRIMARY KEY,
+    actor_id bigint,
+    action varchar(64) NOT NULL,
+    target_type varchar(32) NOT NULL,
+    target_id bigint NOT NULL,
+    before jsonb,
+    after jsonb,
+    request_id text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log (actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log (target_type, target_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+
+-- The audit log is append-only.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update_or_delete
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
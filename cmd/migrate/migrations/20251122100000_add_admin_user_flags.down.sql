@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_comments_created_at;
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP INDEX IF EXISTS idx_users_created_at;
+ALTER TABLE users
+DROP COLUMN IF EXISTS deactivated_at,
+DROP COLUMN IF EXISTS verified_at;
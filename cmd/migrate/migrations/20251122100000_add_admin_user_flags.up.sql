@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS verified_at timestamp(0) with time zone,
+ADD COLUMN IF NOT EXISTS deactivated_at timestamp(0) with time zone;
+
+-- Admin statistics count what was created each day.
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_created_at ON comments (created_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_posts_published_txid;
+DROP INDEX IF EXISTS idx_comments_post_txid;
+DROP INDEX IF EXISTS idx_notifications_user_txid;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS published_txid;
+ALTER TABLE comments DROP COLUMN IF EXISTS txid;
+ALTER TABLE notifications DROP COLUMN IF EXISTS txid;
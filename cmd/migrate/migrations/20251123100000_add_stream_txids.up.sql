@@ -0,0 +1,19 @@
+-- Streams read rows in the order their transactions committed, which IDs
+-- handed out before the commit do not follow. Rows written before this
+-- migration get transaction ID 0, so an old cursor at ID n resumes at
+-- (0, n).
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS txid bigint NOT NULL DEFAULT 0;
+ALTER TABLE notifications ALTER COLUMN txid SET DEFAULT txid_current();
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS txid bigint NOT NULL DEFAULT 0;
+ALTER TABLE comments ALTER COLUMN txid SET DEFAULT txid_current();
+
+-- published_txid is the transaction that published a post, set alongside
+-- published_seq.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_txid bigint;
+UPDATE posts SET published_txid = 0 WHERE published_seq IS NOT NULL;
+ALTER TABLE posts ALTER COLUMN published_txid SET DEFAULT txid_current();
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_txid ON notifications (user_id, txid, id);
+CREATE INDEX IF NOT EXISTS idx_comments_post_txid ON comments (post_id, txid, id);
+CREATE INDEX IF NOT EXISTS idx_posts_published_txid ON posts (published_txid, published_seq);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_messages_conversation_txid;
+
+ALTER TABLE conversation_members DROP COLUMN IF EXISTS receipt_txid;
+ALTER TABLE messages DROP COLUMN IF EXISTS txid;
@@ -0,0 +1,12 @@
+-- Messages and receipts are synced in the order their transactions
+-- committed, like the other streams. Rows written before this migration get
+-- transaction ID 0.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS txid bigint NOT NULL DEFAULT 0;
+ALTER TABLE messages ALTER COLUMN txid SET DEFAULT txid_current();
+
+-- receipt_txid is the transaction that last changed a member's receipt, set
+-- alongside receipt_seq.
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS receipt_txid bigint NOT NULL DEFAULT 0;
+ALTER TABLE conversation_members ALTER COLUMN receipt_txid SET DEFAULT txid_current();
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_txid ON messages (conversation_id, txid, id);
@@ -0,0 +1 @@
+ALTER TABLE webhook_delivery_attempts ADD COLUMN IF NOT EXISTS response_body text;
@@ -0,0 +1,3 @@
+-- Deliveries only record the status code of a receiver's response. Bodies
+-- recorded before could hold whatever a receiver chose to answer with.
+ALTER TABLE webhook_delivery_attempts DROP COLUMN IF EXISTS response_body;
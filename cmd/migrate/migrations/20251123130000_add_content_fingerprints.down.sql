@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_user_fingerprint;
+DROP INDEX IF EXISTS idx_posts_user_fingerprint;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS content_fingerprint;
+ALTER TABLE posts DROP COLUMN IF EXISTS content_fingerprint;
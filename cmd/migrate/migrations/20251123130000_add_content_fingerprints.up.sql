@@ -0,0 +1,8 @@
+-- content_fingerprint identifies what a post or comment said when it was
+-- created, normalized by the content filter, so repeats can be counted
+-- across API instances and restarts.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_fingerprint bytea;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_fingerprint bytea;
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_fingerprint ON posts (user_id, content_fingerprint, created_at);
+CREATE INDEX IF NOT EXISTS idx_comments_user_fingerprint ON comments (user_id, content_fingerprint, created_at);
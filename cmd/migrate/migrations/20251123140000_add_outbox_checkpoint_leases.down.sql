@@ -0,0 +1,2 @@
+ALTER TABLE outbox_checkpoints DROP COLUMN IF EXISTS leased_until;
+ALTER TABLE outbox_checkpoints DROP COLUMN IF EXISTS lease;
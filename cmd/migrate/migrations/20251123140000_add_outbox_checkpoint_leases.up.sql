@@ -0,0 +1,6 @@
+-- A relay leases a subscriber's checkpoint while it delivers a batch,
+-- instead of holding a row lock, so no transaction stays open while
+-- handlers run. lease counts the claims, so a relay whose lease expired
+-- and was taken over can tell it may no longer move the checkpoint.
+ALTER TABLE outbox_checkpoints ADD COLUMN IF NOT EXISTS lease bigint NOT NULL DEFAULT 0;
+ALTER TABLE outbox_checkpoints ADD COLUMN IF NOT EXISTS leased_until timestamp(0) with time zone;
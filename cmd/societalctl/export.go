@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+)
+
+// exportPageSize is how many rows an export fetches at a time.
+const exportPageSize = 100
+
+// UserExport is everything stored about a user.
+type UserExport struct {
+	User        *store.User          `json:"user"`
+	Posts       []store.AdminPost    `json:"posts"`
+	Comments    []store.AdminComment `json:"comments"`
+	Followers   []store.AdminFollow  `json:"followers"`
+	Following   []store.AdminFollow  `json:"following"`
+	Suspensions []store.Suspension   `json:"suspensions"`
+	ExportedAt  time.Time            `json:"exported_at"`
+}
+
+func (c *ctl) export(ctx context.Context, args []string) (any, error) {
+	if len(args) == 0 || args[0] != "user" {
+		return nil, fmt.Errorf("%w: export needs a subcommand", errUsage)
+	}
+	userID, err := parseID("export user", args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserExport{ExportedAt: time.Now().UTC()}
+	if export.User, err = c.store.Users.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if export.Posts, err = collect(func(aq store.AdminListQuery) ([]store.AdminPost, error) {
+		return c.store.Admin.GetUserPosts(ctx, userID, aq)
+	}); err != nil {
+		return nil, err
+	}
+	if export.Comments, err = collect(func(aq store.AdminListQuery) ([]store.AdminComment, error) {
+		return c.store.Admin.GetUserComments(ctx, userID, aq)
+	}); err != nil {
+		return nil, err
+	}
+	if export.Followers, err = collect(func(aq store.AdminListQuery) ([]store.AdminFollow, error) {
+		return c.store.Admin.GetFollowers(ctx, userID, aq)
+	}); err != nil {
+		return nil, err
+	}
+	if export.Following, err = collect(func(aq store.AdminListQuery) ([]store.AdminFollow, error) {
+		return c.store.Admin.GetFollowing(ctx, userID, aq)
+	}); err != nil {
+		return nil, err
+	}
+	if export.Suspensions, err = collect(func(aq store.AdminListQuery) ([]store.Suspension, error) {
+		return c.store.Suspensions.List(ctx, store.SuspensionQuery{Limit: aq.Limit, Offset: aq.Offset, UserID: userID})
+	}); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// collect pages through a listing until it runs out.
+func collect[T any](fetch func(store.AdminListQuery) ([]T, error)) ([]T, error) {
+	all := []T{}
+	aq := store.AdminListQuery{Limit: exportPageSize}
+	for {
+		page, err := fetch(aq)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < aq.Limit {
+			return all, nil
+		}
+		aq.Offset += len(page)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Chandan185/Societal/internal/jobs"
+)
+
+func (c *ctl) jobs(ctx context.Context, args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: jobs needs a subcommand", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("jobs list", flag.ContinueOnError)
+		status := flags.String("status", "", "only jobs with this status: pending, running or dead")
+		limit := flags.Int("limit", 50, "how many jobs to list")
+		rest, err := parseFlags(flags, args[1:])
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(rest) != 0 || *limit < 1:
+			return nil, fmt.Errorf("%w: jobs list", errUsage)
+		case *status != "" && *status != jobs.StatusPending && *status != jobs.StatusRunning && *status != jobs.StatusDead:
+			return nil, fmt.Errorf("%w: invalid status %q", errUsage, *status)
+		}
+		return jobs.List(ctx, c.db, *status, *limit)
+	case "retry":
+		jobID, err := parseID("jobs retry", args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if err := jobs.Retry(ctx, c.db, jobID); err != nil {
+			return nil, err
+		}
+		return map[string]any{"id": jobID, "status": jobs.StatusPending}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown command jobs %s", errUsage, args[0])
+	}
+}
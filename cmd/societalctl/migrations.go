@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// MigrationStatus compares the schema version golang-migrate recorded in
+// the database with the migrations on disk.
+type MigrationStatus struct {
+	// Version is the last migration applied, or 0 when none has been.
+	Version uint64 `json:"version"`
+	// Dirty is set when a migration failed partway and needs fixing by
+	// hand before migrating again.
+	Dirty    bool     `json:"dirty"`
+	Latest   uint64   `json:"latest"`
+	Pending  []string `json:"pending"`
+	UpToDate bool     `json:"up_to_date"`
+}
+
+func (c *ctl) migrations(ctx context.Context, args []string) (any, error) {
+	if len(args) == 0 || args[0] != "status" {
+		return nil, fmt.Errorf("%w: migrations needs a subcommand", errUsage)
+	}
+	flags := flag.NewFlagSet("migrations status", flag.ContinueOnError)
+	dir := flags.String("dir", "./cmd/migrate/migrations", "directory holding the migrations")
+	rest, err := parseFlags(flags, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: migrations status takes no arguments", errUsage)
+	}
+
+	migrations, err := readMigrations(*dir)
+	if err != nil {
+		return nil, err
+	}
+	status := &MigrationStatus{Pending: []string{}}
+	err = c.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&status.Version, &status.Dirty)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case errors.As(err, &pqErr) && pqErr.Code == "42P01":
+			// Nothing was ever migrated, so there is no table yet.
+		default:
+			return nil, err
+		}
+	}
+	for _, m := range migrations {
+		if m.version > status.Version {
+			status.Pending = append(status.Pending, m.name)
+		}
+		status.Latest = m.version
+	}
+	status.UpToDate = len(status.Pending) == 0 && !status.Dirty
+	return status, nil
+}
+
+type migration struct {
+	version uint64
+	name    string
+}
+
+// readMigrations lists the up migrations in dir, oldest first. They are
+// named like 20250923154554_create_users.up.sql.
+func readMigrations(dir string) ([]migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []migration
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".up.sql")
+		if e.IsDir() || !ok {
+			continue
+		}
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version", e.Name())
+		}
+		migrations = append(migrations, migration{version: version, name: name})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
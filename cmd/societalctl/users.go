@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Passwords are hashed with bcrypt, which ignores anything past 72 bytes.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+func (c *ctl) users(ctx context.Context, args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: users needs a subcommand", errUsage)
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "search":
+		return c.searchUsers(ctx, args)
+	case "get":
+		userID, err := parseID("users get", args)
+		if err != nil {
+			return nil, err
+		}
+		return c.store.Users.GetByID(ctx, userID)
+	case "create":
+		return c.createUser(ctx, args)
+	case "set-role":
+		return c.setRole(ctx, args)
+	case "set-password":
+		return c.setPassword(ctx, args)
+	case "verify", "unverify":
+		return c.updateUser(ctx, "users "+name, args, func(userID int64) error {
+			return c.store.Users.SetVerified(ctx, userID, name == "verify")
+		})
+	case "deactivate", "reactivate":
+		return c.updateUser(ctx, "users "+name, args, func(userID int64) error {
+			return c.store.Users.SetActive(ctx, userID, name == "reactivate")
+		})
+	case "suspend":
+		return c.suspendUser(ctx, args)
+	case "unsuspend":
+		userID, err := parseID("users unsuspend", args)
+		if err != nil {
+			return nil, err
+		}
+		return c.store.Suspensions.Lift(ctx, userID, c.actorID)
+	default:
+		return nil, fmt.Errorf("%w: unknown command users %s", errUsage, name)
+	}
+}
+
+func (c *ctl) searchUsers(ctx context.Context, args []string) (any, error) {
+	flags := flag.NewFlagSet("users search", flag.ContinueOnError)
+	uq := store.AdminUserQuery{}
+	flags.StringVar(&uq.Search, "q", "", "part of a username or email address")
+	flags.StringVar(&uq.Role, "role", "", "only users with this role")
+	flags.IntVar(&uq.Limit, "limit", 50, "how many users to list")
+	flags.IntVar(&uq.Offset, "offset", 0, "how many users to skip")
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 0 || uq.Limit < 1 || uq.Offset < 0 {
+		return nil, fmt.Errorf("%w: users search", errUsage)
+	}
+	if uq.Role != "" && !validRole(uq.Role) {
+		return nil, fmt.Errorf("%w: invalid role %q", errUsage, uq.Role)
+	}
+	return c.store.Users.Search(ctx, uq)
+}
+
+func (c *ctl) createUser(ctx context.Context, args []string) (any, error) {
+	flags := flag.NewFlagSet("users create", flag.ContinueOnError)
+	username := flags.String("username", "", "username")
+	email := flags.String("email", "", "email address")
+	role := flags.String("role", store.RoleUser, "user, moderator or admin")
+	private := flags.Bool("private", false, "make the account private")
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(args) != 0:
+		return nil, fmt.Errorf("%w: users create takes no arguments", errUsage)
+	case *username == "" || len(*username) > 255:
+		return nil, fmt.Errorf("%w: users create needs a -username of at most 255 characters", errUsage)
+	case !validRole(*role):
+		return nil, fmt.Errorf("%w: invalid role %q", errUsage, *role)
+	}
+	if _, err := mail.ParseAddress(*email); err != nil {
+		return nil, fmt.Errorf("%w: users create needs a valid -email", errUsage)
+	}
+	password, err := c.readPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &store.User{
+		Username:  *username,
+		Email:     *email,
+		Password:  string(password),
+		IsPrivate: *private,
+	}
+	if err := c.store.Users.CreateWithRole(ctx, user, *role); err != nil {
+		return nil, err
+	}
+	return c.store.Users.GetByID(ctx, user.ID)
+}
+
+func (c *ctl) setRole(ctx context.Context, args []string) (any, error) {
+	if len(args) != 2 || !validRole(args[1]) {
+		return nil, fmt.Errorf("%w: users set-role takes an ID and user, moderator or admin", errUsage)
+	}
+	role := args[1]
+	return c.updateUser(ctx, "users set-role", args[:1], func(userID int64) error {
+		return c.store.Users.SetRole(ctx, userID, role)
+	})
+}
+
+func (c *ctl) setPassword(ctx context.Context, args []string) (any, error) {
+	if _, err := parseID("users set-password", args); err != nil {
+		return nil, err
+	}
+	password, err := c.readPassword()
+	if err != nil {
+		return nil, err
+	}
+	return c.updateUser(ctx, "users set-password", args, func(userID int64) error {
+		return c.store.Users.SetPassword(ctx, userID, password)
+	})
+}
+
+func (c *ctl) suspendUser(ctx context.Context, args []string) (any, error) {
+	flags := flag.NewFlagSet("users suspend", flag.ContinueOnError)
+	reason := flags.String("reason", "", "why the user is suspended")
+	days := flags.Int("days", 0, "how many days the suspension lasts; 0 suspends indefinitely")
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID("users suspend", args)
+	if err != nil {
+		return nil, err
+	}
+	if *reason == "" || len(*reason) > 1000 || *days < 0 || *days > 3650 {
+		return nil, fmt.Errorf("%w: users suspend needs a -reason and at most 3650 -days", errUsage)
+	}
+
+	suspension := &store.Suspension{
+		UserID:   userID,
+		Reason:   *reason,
+		IssuedBy: c.actorID,
+	}
+	if *days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, *days)
+		suspension.ExpiresAt = &expiresAt
+	}
+	if err := c.store.Suspensions.Create(ctx, suspension); err != nil {
+		return nil, err
+	}
+	return suspension, nil
+}
+
+// updateUser applies update to the user whose ID args holds and returns
+// the updated user.
+func (c *ctl) updateUser(ctx context.Context, name string, args []string, update func(int64) error) (any, error) {
+	userID, err := parseID(name, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(userID); err != nil {
+		return nil, err
+	}
+	return c.store.Users.GetByID(ctx, userID)
+}
+
+// readPassword reads a password from the first line of standard input and
+// hashes it.
+func (c *ctl) readPassword() ([]byte, error) {
+	line, err := bufio.NewReader(c.in).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, errors.New("reading the password from standard input: " + err.Error())
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, fmt.Errorf("passwords must be %d to %d bytes long", minPasswordLength, maxPasswordLength)
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+func validRole(role string) bool {
+	switch role {
+	case store.RoleUser, store.RoleModerator, store.RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
@@ -54,10 +54,11 @@ func generatePosts(users []*store.User, n int) []*store.Post {
 	for i := 0; i < n; i++ {
 		user := users[i%userCount]
 		posts[i] = &store.Post{
-			Title:   "Post Title " + strconv.Itoa(i),
-			Content: "This is the content of post number " + strconv.Itoa(i),
-			USERID:  user.ID,
-			Tags:    []string{"tag1", "tag2"},
+			Title:      "Post Title " + strconv.Itoa(i),
+			Content:    "This is the content of post number " + strconv.Itoa(i),
+			USERID:     user.ID,
+			Tags:       []string{"tag1", "tag2"},
+			Visibility: store.VisibilityPublic,
 		}
 	}
 	return posts
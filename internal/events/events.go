@@ -0,0 +1,41 @@
+// Package events implements a transactional outbox. Writes record domain
+// events in the same transaction as the change they describe, and a Relay
+// delivers the committed events to in-process subscribers, so a side effect
+// is never lost to a crash between the commit and acting on it.
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Decode unmarshals the payload of e into v.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Execer is satisfied by *sql.Tx. Events should be recorded in the
+// transaction of the write they describe.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Record adds an event of the given type to the outbox. It is delivered once
+// the transaction of q commits, and never if it rolls back.
+func Record(ctx context.Context, q Execer, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `INSERT INTO outbox (type, payload) VALUES ($1, $2)`, eventType, data)
+	return err
+}
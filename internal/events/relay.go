@@ -0,0 +1,277 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	// batchSize caps how many events one claim on a subscriber handles.
+	batchSize = 100
+	// leaseDuration is how long a relay may deliver a batch to a subscriber
+	// before another relay assumes it died and takes the subscriber over.
+	leaseDuration = 5 * time.Minute
+	// pruneInterval is how often delivered events past their retention are
+	// removed.
+	pruneInterval = time.Hour
+)
+
+// Handler acts on a delivered event. Delivery is at least once: a handler
+// may see an event again after a crash or a failed checkpoint and must be
+// idempotent. An error stops delivery to the subscriber, which retries the
+// event after the poll interval.
+type Handler func(ctx context.Context, e Event) error
+
+type Config struct {
+	// PollInterval is how often subscribers look for new events.
+	PollInterval time.Duration
+	// Retention is how long events are kept after every subscriber has
+	// been handed them.
+	Retention time.Duration
+}
+
+type subscriber struct {
+	name    string
+	types   []string
+	handler Handler
+	wake    chan struct{}
+}
+
+// Relay delivers recorded events to subscribers in the order they were
+// recorded. Each subscriber has a checkpoint of its own, so a slow or
+// failing subscriber does not hold up the others. A relay leases the
+// checkpoint while it delivers a batch, so when several API instances run
+// a relay each batch reaches a subscriber through one of them only. No
+// transaction is held open while handlers run: one would hold back the
+// snapshot horizon that commit-ordered readers, this one included, wait
+// for.
+type Relay struct {
+	db          *sql.DB
+	logger      *zap.SugaredLogger
+	cfg         Config
+	subscribers []*subscriber
+}
+
+func NewRelay(db *sql.DB, logger *zap.SugaredLogger, cfg Config) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+	return &Relay{
+		db:     db,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Subscribe registers handler under name for events of the given types, or
+// of every type when none are given. Subscribers must be registered before
+// Run is called. A new subscriber receives the events recorded from its
+// first run on; the name keys its checkpoint and must stay stable.
+func (r *Relay) Subscribe(name string, handler Handler, types ...string) {
+	r.subscribers = append(r.subscribers, &subscriber{
+		name:    name,
+		types:   append([]string{}, types...),
+		handler: handler,
+		wake:    make(chan struct{}, 1),
+	})
+}
+
+// Notify wakes idle subscribers, typically right after a write recorded
+// events, instead of leaving them to the next poll.
+func (r *Relay) Notify() {
+	for _, s := range r.subscribers {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run delivers events to the subscribers until ctx is cancelled and prunes
+// old events.
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range r.subscribers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.deliver(ctx, s)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.prune(ctx)
+	}()
+	wg.Wait()
+}
+
+func (r *Relay) deliver(ctx context.Context, s *subscriber) {
+	if err := r.register(ctx, s); err != nil {
+		r.logger.Errorw("failed to register event subscriber", "subscriber", s.name, "error", err)
+		return
+	}
+	for {
+		for ctx.Err() == nil {
+			n, err := r.deliverBatch(ctx, s)
+			if err != nil {
+				r.logger.Errorw("failed to deliver events", "subscriber", s.name, "error", err)
+				break
+			}
+			if n < batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// register creates the checkpoint of a new subscriber just before the
+// first event that may still be uncommitted.
+func (r *Relay) register(ctx context.Context, s *subscriber) error {
+	query := `INSERT INTO outbox_checkpoints (subscriber, last_txid, last_event_id)
+	VALUES ($1, txid_snapshot_xmin(txid_current_snapshot()) - 1, 9223372036854775807)
+	ON CONFLICT (subscriber) DO NOTHING`
+	_, err := r.db.ExecContext(ctx, query, s.name)
+	return err
+}
+
+// deliverBatch hands the next batch of events to s and moves its checkpoint
+// past the ones it handled. It returns how many events were delivered.
+func (r *Relay) deliverBatch(ctx context.Context, s *subscriber) (int, error) {
+	var lease, lastTxID, lastID int64
+	query := `UPDATE outbox_checkpoints SET lease = lease + 1, leased_until = NOW() + $2 * interval '1 second'
+	WHERE subscriber = $1 AND (leased_until IS NULL OR leased_until < NOW())
+	RETURNING lease, last_txid, last_event_id`
+	err := r.db.QueryRowContext(ctx, query, s.name, leaseDuration.Seconds()).Scan(&lease, &lastTxID, &lastID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Another instance is delivering to this subscriber.
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// Only transactions older than every running one are read, so an event
+	// committed later always sorts after the checkpoint.
+	query = `SELECT id, txid, type, payload, created_at FROM outbox
+	WHERE (txid, id) > ($2, $3) AND txid < txid_snapshot_xmin(txid_current_snapshot()) AND
+		(cardinality($1::text[]) = 0 OR type = ANY($1))
+	ORDER BY txid, id
+	LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(s.types), lastTxID, lastID, batchSize)
+	if err != nil {
+		return 0, errors.Join(err, r.checkpoint(ctx, s, lease, lastTxID, lastID))
+	}
+	type position struct{ txID, id int64 }
+	var batch []Event
+	var positions []position
+	for rows.Next() {
+		var e Event
+		var pos position
+		if err := rows.Scan(&e.ID, &pos.txID, &e.Type, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, errors.Join(err, r.checkpoint(ctx, s, lease, lastTxID, lastID))
+		}
+		pos.id = e.ID
+		batch = append(batch, e)
+		positions = append(positions, pos)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errors.Join(err, r.checkpoint(ctx, s, lease, lastTxID, lastID))
+	}
+
+	delivered := 0
+	var deliverErr error
+	for _, e := range batch {
+		if deliverErr = r.handle(ctx, s, e); deliverErr != nil {
+			deliverErr = fmt.Errorf("event %d (%s): %w", e.ID, e.Type, deliverErr)
+			break
+		}
+		delivered++
+	}
+	if delivered > 0 {
+		last := positions[delivered-1]
+		lastTxID, lastID = last.txID, last.id
+	}
+	if err := r.checkpoint(ctx, s, lease, lastTxID, lastID); err != nil {
+		return 0, errors.Join(deliverErr, err)
+	}
+	return delivered, deliverErr
+}
+
+// errLeaseLost means a batch took so long that another relay took the
+// subscriber over, so the events this relay delivered will be delivered
+// again.
+var errLeaseLost = errors.New("lease on the subscriber expired during delivery")
+
+// checkpoint moves the checkpoint of s to the given position and releases
+// lease, in a transaction of its own, unless the lease has been taken over.
+// It runs even once ctx is cancelled, so that what was delivered is not
+// delivered again.
+func (r *Relay) checkpoint(ctx context.Context, s *subscriber, lease, lastTxID, lastID int64) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	query := `UPDATE outbox_checkpoints SET last_txid = $3, last_event_id = $4, leased_until = NULL, updated_at = NOW()
+	WHERE subscriber = $1 AND lease = $2`
+	res, err := r.db.ExecContext(ctx, query, s.name, lease, lastTxID, lastID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+func (r *Relay) handle(ctx context.Context, s *subscriber, e Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("event handler panicked: %v", rec)
+		}
+	}()
+	return s.handler(ctx, e)
+}
+
+// prune removes events past their retention that every subscriber has
+// been handed.
+func (r *Relay) prune(ctx context.Context) {
+	query := `DELETE FROM outbox o
+	WHERE o.created_at < NOW() - $1 * interval '1 second' AND
+		NOT EXISTS (SELECT 1 FROM outbox_checkpoints c WHERE (c.last_txid, c.last_event_id) < (o.txid, o.id))`
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		res, err := r.db.ExecContext(ctx, query, r.cfg.Retention.Seconds())
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Errorw("failed to prune events", "error", err)
+			}
+		} else if n, _ := res.RowsAffected(); n > 0 {
+			r.logger.Infow("pruned events", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package jobs runs background work from a queue kept in Postgres, and
+// periodic work with RunPeriodic. Jobs are claimed with row locks, skipping
+// rows another worker holds, so any number of API instances can run worker
+// pools against the same queue, and queued work survives restarts. Other
+// background work in the API claims its rows the same way.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDead    = "dead"
+)
+
+// DefaultMaxAttempts is how often a job is tried before it is dead-lettered
+// when EnqueueOptions does not say otherwise.
+const DefaultMaxAttempts = 10
+
+var (
+	ErrDuplicate = errors.New("job with this unique key is already queued")
+	ErrNotFound  = errors.New("job not found")
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	UniqueKey   *string         `json:"unique_key"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   *string         `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, unique_key, run_at, last_error, created_at, updated_at`
+
+func scanJob(row interface{ Scan(...any) error }, j *Job) error {
+	return row.Scan(&j.ID, &j.Kind, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.UniqueKey, &j.RunAt, &j.LastError, &j.CreatedAt, &j.UpdatedAt)
+}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx, so a job can be
+// enqueued in the same transaction as the write that calls for it.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Kind names a type of job whose arguments are a T, stored as JSON.
+type Kind[T any] string
+
+type EnqueueOptions struct {
+	// RunAt delays the job. The zero value runs it right away.
+	RunAt time.Time
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	// UniqueKey, when set, makes Enqueue fail with ErrDuplicate while
+	// another job of the same kind with the same key is pending or running.
+	UniqueKey string
+}
+
+// Enqueue adds a job of kind k with the given arguments and returns its ID.
+func (k Kind[T]) Enqueue(ctx context.Context, q Querier, args T, opts EnqueueOptions) (int64, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return 0, err
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	var runAt, uniqueKey any
+	if !opts.RunAt.IsZero() {
+		runAt = opts.RunAt
+	}
+	if opts.UniqueKey != "" {
+		uniqueKey = opts.UniqueKey
+	}
+
+	query := `INSERT INTO jobs (kind, payload, max_attempts, unique_key, run_at)
+	VALUES ($1, $2, $3, $4, COALESCE($5::timestamptz, NOW()))
+	ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running') DO NOTHING
+	RETURNING id`
+	var id int64
+	err = q.QueryRowContext(ctx, query, string(k), payload, maxAttempts, uniqueKey, runAt).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDuplicate
+	}
+	return id, err
+}
+
+// List returns up to limit jobs with the given status, or with any status
+// when it is empty, oldest first.
+func List(ctx context.Context, q Querier, status string, limit int) ([]Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE $1 = '' OR status = $1 ORDER BY id LIMIT $2`
+	rows, err := q.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		var j Job
+		if err := scanJob(rows, &j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts. It
+// returns ErrNotFound when there is no such dead job and ErrDuplicate when
+// another job with its unique key has been queued since.
+func Retry(ctx context.Context, q Querier, id int64) error {
+	query := `UPDATE jobs SET status = 'pending', attempts = 0, run_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND status = 'dead'
+	RETURNING id`
+	err := q.QueryRowContext(ctx, query, id).Scan(&id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicate
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the job is dead-lettered right away instead
+// of being retried.
+func Permanent(err error) error {
+	return permanentError{err}
+}
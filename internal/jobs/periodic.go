@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// RunPeriodic runs batch every interval until ctx is cancelled, and again
+// right away for as long as batch reports there is more to do. Batches
+// should claim their rows the way the queue claims jobs, so that
+// instances running the same periodic work do not do it twice.
+func RunPeriodic(ctx context.Context, interval time.Duration, batch func(ctx context.Context) (more bool)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil && batch(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry. It doubles with
+	// every further attempt up to retryMaxDelay.
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+	// finishTimeout bounds recording the outcome of a job.
+	finishTimeout = 5 * time.Second
+)
+
+type Config struct {
+	// Concurrency is how many jobs the pool runs at once.
+	Concurrency int
+	// PollInterval is how often idle workers look for due jobs.
+	PollInterval time.Duration
+	// Timeout bounds a single attempt at a job. A job still marked running
+	// well after that is assumed lost with its worker and claimed again.
+	Timeout time.Duration
+}
+
+type handler func(ctx context.Context, payload json.RawMessage) error
+
+// Pool runs the jobs of the kinds registered with Handle.
+type Pool struct {
+	db       *sql.DB
+	logger   *zap.SugaredLogger
+	cfg      Config
+	handlers map[string]handler
+	wake     chan struct{}
+}
+
+func NewPool(db *sql.DB, logger *zap.SugaredLogger, cfg Config) *Pool {
+	cfg.Concurrency = max(1, cfg.Concurrency)
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	return &Pool{
+		db:       db,
+		logger:   logger,
+		cfg:      cfg,
+		handlers: map[string]handler{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Handle registers fn to run jobs of kind k. Handlers must be registered
+// before Run is called. A job whose arguments cannot be decoded is
+// dead-lettered without being retried.
+func Handle[T any](p *Pool, k Kind[T], fn func(ctx context.Context, args T) error) {
+	p.handlers[string(k)] = func(ctx context.Context, payload json.RawMessage) error {
+		var args T
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return Permanent(err)
+		}
+		return fn(ctx, args)
+	}
+}
+
+// Notify wakes an idle worker, typically right after a job was enqueued.
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works through due jobs until ctx is cancelled. It then stops claiming
+// jobs and returns once the jobs already running have finished.
+func (p *Pool) Run(ctx context.Context) {
+	kinds := make([]string, 0, len(p.handlers))
+	for kind := range p.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx, kinds)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context, kinds []string) {
+	for {
+		for p.runNext(ctx, kinds) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-time.After(p.cfg.PollInterval):
+		}
+	}
+}
+
+// runNext runs one due job and reports whether there was one to run.
+func (p *Pool) runNext(ctx context.Context, kinds []string) bool {
+	if ctx.Err() != nil || len(kinds) == 0 {
+		return false
+	}
+	job, err := p.claim(ctx, kinds)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			p.logger.Errorw("failed to claim job", "error", err)
+		}
+		return false
+	}
+
+	// A claimed job runs to the end even when the pool is shutting down, so
+	// the work is not thrown away and done again.
+	detached := context.WithoutCancel(ctx)
+	jobCtx, cancel := context.WithTimeout(detached, p.cfg.Timeout)
+	err = p.execute(jobCtx, job)
+	cancel()
+
+	finishCtx, cancel := context.WithTimeout(detached, finishTimeout)
+	defer cancel()
+	if err == nil {
+		if err := p.complete(finishCtx, job); err != nil {
+			p.logger.Errorw("failed to complete job", "id", job.ID, "kind", job.Kind, "error", err)
+		}
+		return true
+	}
+	if err := p.fail(finishCtx, job, err); err != nil {
+		p.logger.Errorw("failed to record job failure", "id", job.ID, "kind", job.Kind, "error", err)
+	}
+	return true
+}
+
+func (p *Pool) execute(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return p.handlers[job.Kind](ctx, job.Payload)
+}
+
+// claim marks the next due job of one of the given kinds as running and
+// returns it, or ErrNotFound when there is none.
+func (p *Pool) claim(ctx context.Context, kinds []string) (*Job, error) {
+	query := `UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+	WHERE id = (
+		SELECT id FROM jobs
+		WHERE kind = ANY($1) AND run_at <= NOW() AND
+			(status = 'pending' OR (status = 'running' AND locked_at < NOW() - $2 * interval '1 second'))
+		ORDER BY run_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING ` + jobColumns
+	ctx, cancel := context.WithTimeout(ctx, finishTimeout)
+	defer cancel()
+	var j Job
+	lockTimeout := 2 * p.cfg.Timeout
+	if err := scanJob(p.db.QueryRowContext(ctx, query, pq.Array(kinds), lockTimeout.Seconds()), &j); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &j, nil
+}
+
+func (p *Pool) complete(ctx context.Context, job *Job) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM jobs WHERE id = $1`, job.ID)
+	return err
+}
+
+// fail schedules a retry of job after an exponential backoff, or moves it
+// to the dead-letter state once it is out of attempts or failed
+// permanently.
+func (p *Pool) fail(ctx context.Context, job *Job, jobErr error) error {
+	var permanent permanentError
+	if errors.As(jobErr, &permanent) || job.Attempts >= job.MaxAttempts {
+		p.logger.Warnw("job dead-lettered", "id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", jobErr)
+		query := `UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = NOW() WHERE id = $1`
+		_, err := p.db.ExecContext(ctx, query, job.ID, jobErr.Error())
+		return err
+	}
+
+	delay := backoff(job.Attempts)
+	p.logger.Infow("job failed, retrying", "id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "delay", delay, "error", jobErr)
+	query := `UPDATE jobs SET status = 'pending', run_at = NOW() + $3 * interval '1 second', last_error = $2, locked_at = NULL, updated_at = NOW()
+	WHERE id = $1`
+	_, err := p.db.ExecContext(ctx, query, job.ID, jobErr.Error(), delay.Seconds())
+	return err
+}
+
+// backoff returns how long to wait before retrying a job that has failed
+// attempts times, with up to 20% jitter so failed jobs spread out.
+func backoff(attempts int) time.Duration {
+	delay := retryMaxDelay
+	if attempts <= 12 {
+		delay = min(retryBaseDelay<<(attempts-1), retryMaxDelay)
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay/5)+1))
+}
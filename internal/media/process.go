@@ -0,0 +1,167 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels bounds the decoded size of an image so a small, highly
+// compressed upload cannot exhaust memory.
+const maxPixels = 40_000_000
+
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// thumbnailSizes are the variants generated for every image, keyed by name
+// and bounded by the length of their longest side. Variants that would be
+// larger than the original are skipped.
+var thumbnailSizes = []struct {
+	Name    string
+	MaxSide int
+}{
+	{"small", 150},
+	{"medium", 600},
+	{"large", 1200},
+}
+
+type Variant struct {
+	Name        string
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+type Result struct {
+	// Original is the image re-encoded without any metadata. GIFs are kept
+	// as uploaded so animations survive.
+	Original    []byte
+	ContentType string
+	Width       int
+	Height      int
+	Placeholder string
+	Variants    []Variant
+}
+
+func IsImage(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/")
+}
+
+// ProcessImage strips metadata from an uploaded image, records its
+// dimensions and a BlurHash placeholder, and renders its thumbnails.
+func ProcessImage(data []byte, contentType string) (*Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	// JPEGs stay JPEGs; everything else becomes PNG to keep transparency.
+	outType := "image/png"
+	if contentType == "image/jpeg" {
+		outType = "image/jpeg"
+	}
+
+	b := img.Bounds()
+	res := &Result{
+		Width:       b.Dx(),
+		Height:      b.Dy(),
+		ContentType: outType,
+		Placeholder: blurhash(resize(img, 32), 4, 3),
+	}
+
+	if contentType == "image/gif" {
+		res.Original = data
+		res.ContentType = contentType
+	} else {
+		res.Original, err = encode(img, outType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, size := range thumbnailSizes {
+		if max(res.Width, res.Height) <= size.MaxSide {
+			continue
+		}
+		thumb := resize(img, size.MaxSide)
+		data, err := encode(thumb, outType)
+		if err != nil {
+			return nil, err
+		}
+		tb := thumb.Bounds()
+		res.Variants = append(res.Variants, Variant{
+			Name:        size.Name,
+			ContentType: outType,
+			Width:       tb.Dx(),
+			Height:      tb.Dy(),
+			Data:        data,
+		})
+	}
+	return res, nil
+}
+
+// resize scales img down so its longest side is at most maxSide, keeping the
+// aspect ratio. Images that already fit are returned unchanged.
+func resize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// extension returns the file extension used when storing contentType.
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".png"
+	}
+}
@@ -0,0 +1,183 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/blob"
+	"github.com/Chandan185/Societal/internal/store"
+	"go.uber.org/zap"
+)
+
+// Processor turns uploaded images into their published form in the
+// background. Workers claim pending media from the database, the way the
+// job queue claims jobs.
+type Processor struct {
+	store        store.Storage
+	blobs        blob.BlobStore
+	logger       *zap.SugaredLogger
+	workers      int
+	pollInterval time.Duration
+	wake         chan struct{}
+}
+
+func NewProcessor(store store.Storage, blobs blob.BlobStore, logger *zap.SugaredLogger, workers int) *Processor {
+	return &Processor{
+		store:        store,
+		blobs:        blobs,
+		logger:       logger,
+		workers:      max(1, workers),
+		pollInterval: 10 * time.Second,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes an idle worker, typically right after an upload.
+func (p *Processor) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes media until ctx is cancelled.
+func (p *Processor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Processor) work(ctx context.Context) {
+	for {
+		for p.processNext(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// processNext handles one pending media item and reports whether there was
+// one to handle.
+func (p *Processor) processNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	m, err := p.store.Media.ClaimPending(ctx)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			p.logger.Errorw("failed to claim media", "error", err)
+		}
+		return false
+	}
+	err = p.process(ctx, m)
+	if errors.Is(err, store.ErrClaimLost) {
+		p.logger.Infow("media was claimed again while processing", "id", m.ID)
+		return true
+	}
+	if err != nil {
+		p.logger.Warnw("media processing failed", "id", m.ID, "error", err)
+		if err := p.store.Media.MarkFailed(ctx, m); err != nil && !errors.Is(err, store.ErrClaimLost) {
+			p.logger.Errorw("failed to mark media as failed", "id", m.ID, "error", err)
+		}
+	}
+	return true
+}
+
+func (p *Processor) process(ctx context.Context, m *store.Media) (err error) {
+	if !IsImage(m.ContentType) {
+		m.Variants = []store.MediaVariant{}
+		return p.store.Media.MarkReady(ctx, m)
+	}
+
+	rc, err := p.blobs.Get(ctx, m.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	res, err := ProcessImage(data, m.ContentType)
+	if err != nil {
+		return err
+	}
+
+	// Each claim writes under keys of its own, so when the result is not
+	// kept, because processing failed or another worker claimed the media
+	// again, what was written can be removed without touching anyone
+	// else's files or the upload itself.
+	oldKey := m.StorageKey
+	base := strings.TrimSuffix(oldKey, path.Ext(oldKey)) + "_" + strconv.FormatInt(m.ClaimedAt.Unix(), 36)
+	var written []string
+	defer func() {
+		if err != nil {
+			p.removeBlobs(context.WithoutCancel(ctx), m.ID, written)
+		}
+	}()
+
+	newKey := base + extension(res.ContentType)
+	if err := p.blobs.Put(ctx, newKey, bytes.NewReader(res.Original)); err != nil {
+		return err
+	}
+	written = append(written, newKey)
+
+	m.Variants = make([]store.MediaVariant, 0, len(res.Variants))
+	for _, v := range res.Variants {
+		key := base + "_" + v.Name + extension(v.ContentType)
+		if err := p.blobs.Put(ctx, key, bytes.NewReader(v.Data)); err != nil {
+			return err
+		}
+		written = append(written, key)
+		m.Variants = append(m.Variants, store.MediaVariant{
+			Name:        v.Name,
+			StorageKey:  key,
+			ContentType: v.ContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+			SizeBytes:   int64(len(v.Data)),
+		})
+	}
+
+	m.StorageKey = newKey
+	m.ContentType = res.ContentType
+	m.SizeBytes = int64(len(res.Original))
+	m.Width = res.Width
+	m.Height = res.Height
+	m.Placeholder = res.Placeholder
+	if err := p.store.Media.MarkReady(ctx, m); err != nil {
+		return err
+	}
+
+	if err := p.blobs.Delete(ctx, oldKey); err != nil {
+		p.logger.Warnw("failed to remove unprocessed original", "id", m.ID, "key", oldKey, "error", err)
+	}
+	return nil
+}
+
+// removeBlobs deletes files written for a result that was not kept.
+func (p *Processor) removeBlobs(ctx context.Context, mediaID int64, keys []string) {
+	for _, key := range keys {
+		if err := p.blobs.Delete(ctx, key); err != nil {
+			p.logger.Warnw("failed to remove unused processed media", "id", mediaID, "key", key, "error", err)
+		}
+	}
+}
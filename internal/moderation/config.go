@@ -0,0 +1,99 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config describes a filter in JSON, for example:
+//
+//	{
+//	  "banned_words": [{"decision": "reject", "words": ["slur", "two words"]}],
+//	  "blocked_domains": [{"decision": "flag", "domains": ["spam.example"]}],
+//	  "patterns": [{"name": "phone number", "decision": "flag", "pattern": "\\d{3}-\\d{3}-\\d{4}"}],
+//	  "repeated_content": {"decision": "flag", "max": 3, "window": "10m"}
+//	}
+type Config struct {
+	BannedWords []struct {
+		Decision Decision `json:"decision"`
+		Words    []string `json:"words"`
+	} `json:"banned_words"`
+	BlockedDomains []struct {
+		Decision Decision `json:"decision"`
+		Domains  []string `json:"domains"`
+	} `json:"blocked_domains"`
+	Patterns []struct {
+		Name     string   `json:"name"`
+		Decision Decision `json:"decision"`
+		Pattern  string   `json:"pattern"`
+	} `json:"patterns"`
+	RepeatedContent *struct {
+		Decision Decision `json:"decision"`
+		Max      int      `json:"max"`
+		Window   string   `json:"window"`
+	} `json:"repeated_content"`
+}
+
+// DefaultConfig only flags content a user repeats more than three times in
+// ten minutes.
+const DefaultConfig = `{"repeated_content": {"decision": "flag", "max": 3, "window": "10m"}}`
+
+// Load reads a filter from the JSON config file at path, or uses
+// DefaultConfig when path is empty. Repeats are counted with counter.
+func Load(path string, counter RepeatCounter) (*Filter, error) {
+	data := []byte(DefaultConfig)
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Filter(counter)
+}
+
+// Filter builds the filter cfg describes, counting repeats with counter.
+func (cfg Config) Filter(counter RepeatCounter) (*Filter, error) {
+	var rules []Rule
+	for i, l := range cfg.BannedWords {
+		if !l.Decision.valid() {
+			return nil, fmt.Errorf("banned_words[%d]: invalid decision %q", i, l.Decision)
+		}
+		rules = append(rules, NewWordList(l.Decision, l.Words...))
+	}
+	for i, l := range cfg.BlockedDomains {
+		if !l.Decision.valid() {
+			return nil, fmt.Errorf("blocked_domains[%d]: invalid decision %q", i, l.Decision)
+		}
+		rules = append(rules, NewDomainBlocklist(l.Decision, l.Domains...))
+	}
+	for i, p := range cfg.Patterns {
+		if !p.Decision.valid() {
+			return nil, fmt.Errorf("patterns[%d]: invalid decision %q", i, p.Decision)
+		}
+		rule, err := NewPattern(p.Name, p.Decision, p.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if r := cfg.RepeatedContent; r != nil {
+		if !r.Decision.valid() {
+			return nil, fmt.Errorf("repeated_content: invalid decision %q", r.Decision)
+		}
+		window, err := time.ParseDuration(r.Window)
+		if err != nil {
+			return nil, fmt.Errorf("repeated_content: %w", err)
+		}
+		if r.Max < 1 || window <= 0 {
+			return nil, fmt.Errorf("repeated_content: max and window must be positive")
+		}
+		rules = append(rules, NewRepeatedContent(counter, r.Decision, r.Max, window))
+	}
+	return New(rules...), nil
+}
@@ -0,0 +1,117 @@
+// Package moderation screens posts and comments before they are saved.
+// A Filter runs content through a set of rules, each of which can let it
+// through, flag it for a moderator to review or reject it outright.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Decision is what a rule, or a whole filter, makes of some content.
+type Decision string
+
+const (
+	Allow  Decision = "allow"
+	Flag   Decision = "flag"
+	Reject Decision = "reject"
+)
+
+func (d Decision) severity() int {
+	switch d {
+	case Reject:
+		return 2
+	case Flag:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d Decision) valid() bool {
+	return d == Flag || d == Reject
+}
+
+// Field is one piece of text in a payload, named as in the request body.
+type Field struct {
+	Name string
+	Text string
+}
+
+// Content is a post or comment about to be created or edited.
+type Content struct {
+	// Kind is "post" or "comment".
+	Kind   string
+	UserID int64
+	// Edit is set when existing content is being changed.
+	Edit   bool
+	Fields []Field
+}
+
+// Finding is a rule matching a field.
+type Finding struct {
+	Rule     string
+	Field    string
+	Reason   string
+	Decision Decision
+}
+
+// Result is the outcome of screening content: the most severe decision of
+// any rule and what each rule found.
+type Result struct {
+	Decision Decision
+	Findings []Finding
+}
+
+// Rejections returns why each rejected field was rejected.
+func (r Result) Rejections() map[string]string {
+	fields := map[string]string{}
+	for _, f := range r.Findings {
+		if _, ok := fields[f.Field]; !ok && f.Decision == Reject {
+			fields[f.Field] = f.Reason
+		}
+	}
+	return fields
+}
+
+// Summary describes the findings in a line, for moderators.
+func (r Result) Summary() string {
+	parts := make([]string, len(r.Findings))
+	for i, f := range r.Findings {
+		parts[i] = fmt.Sprintf("%s: %s (%s)", f.Field, f.Reason, f.Rule)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Rule screens content. Rules must be safe for concurrent use.
+type Rule interface {
+	Check(ctx context.Context, c Content) ([]Finding, error)
+}
+
+type Filter struct {
+	rules []Rule
+}
+
+// New returns a filter applying rules in order.
+func New(rules ...Rule) *Filter {
+	return &Filter{rules: rules}
+}
+
+// Check runs c through every rule. Content no rule objects to is allowed.
+func (f *Filter) Check(ctx context.Context, c Content) (Result, error) {
+	result := Result{Decision: Allow}
+	for _, rule := range f.rules {
+		findings, err := rule.Check(ctx, c)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, finding := range findings {
+			if finding.Decision.severity() > result.Decision.severity() {
+				result.Decision = finding.Decision
+			}
+		}
+		result.Findings = append(result.Findings, findings...)
+	}
+	return result, nil
+}
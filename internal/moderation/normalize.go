@@ -0,0 +1,42 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// lookalikes undoes the usual character swaps used to sneak words past a
+// filter.
+var lookalikes = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t",
+	"@", "a", "$", "s",
+)
+
+// normalize folds text so that variants of a word compare equal: compatibility
+// forms such as full-width letters are unified, accents are dropped, case is
+// folded and lookalike digits and symbols are read as letters.
+func normalize(text string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, text)
+	if err != nil {
+		folded = text
+	}
+	return lookalikes.Replace(strings.ToLower(folded))
+}
+
+// words splits normalized text into its words.
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// normalizeWidth only unifies compatibility forms, leaving case, accents
+// and symbols be.
+func normalizeWidth(text string) string {
+	return norm.NFKC.String(text)
+}
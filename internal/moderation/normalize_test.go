@@ -0,0 +1,32 @@
+package moderation
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"case", "HeLLo", "hello"},
+		{"accents", "naïve café Ångström", "naive cafe angstrom"},
+		{"full-width letters", "Ｈｅｌｌｏ", "hello"},
+		{"ligatures", "ﬁne", "fine"},
+		{"lookalike digits", "h3ll0 w0r1d", "hello worid"},
+		{"lookalike symbols", "$p@m", "spam"},
+		{"punctuation is kept", "a-b, c!", "a-b, c!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.text); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedPhrase(t *testing.T) {
+	if got, want := normalizedPhrase("  Two\tWÖRDS!! "), "two words"; got != want {
+		t.Errorf("normalizedPhrase = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// WordList matches banned words and phrases however they are cased,
+// accented or spelled with lookalike characters. Only whole words match, so
+// banning "ass" leaves "class" alone.
+type WordList struct {
+	decision Decision
+	phrases  []string
+}
+
+// NewWordList returns a rule deciding decision on text containing any of
+// words.
+func NewWordList(decision Decision, words ...string) *WordList {
+	l := &WordList{decision: decision}
+	for _, w := range words {
+		if phrase := normalizedPhrase(w); phrase != "" {
+			l.phrases = append(l.phrases, phrase)
+		}
+	}
+	return l
+}
+
+// normalizedPhrase joins the normalized words of text with single spaces.
+func normalizedPhrase(text string) string {
+	return strings.Join(words(normalize(text)), " ")
+}
+
+func (l *WordList) Check(ctx context.Context, c Content) ([]Finding, error) {
+	var findings []Finding
+	for _, field := range c.Fields {
+		text := " " + normalizedPhrase(field.Text) + " "
+		for _, phrase := range l.phrases {
+			if strings.Contains(text, " "+phrase+" ") {
+				findings = append(findings, Finding{
+					Rule:     "banned_words",
+					Field:    field.Name,
+					Reason:   "contains a banned word",
+					Decision: l.decision,
+				})
+				break
+			}
+		}
+	}
+	return findings, nil
+}
+
+// hostPattern finds host names in text, whether in a URL, an email address
+// or on their own.
+var hostPattern = regexp.MustCompile(`(?i)\b((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,63})\b`)
+
+// DomainBlocklist matches links to blocked domains and their subdomains.
+type DomainBlocklist struct {
+	decision Decision
+	domains  map[string]bool
+}
+
+// NewDomainBlocklist returns a rule deciding decision on text linking to
+// any of domains.
+func NewDomainBlocklist(decision Decision, domains ...string) *DomainBlocklist {
+	l := &DomainBlocklist{decision: decision, domains: map[string]bool{}}
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d != "" {
+			l.domains[d] = true
+		}
+	}
+	return l
+}
+
+// blocked reports whether host or any domain it belongs to is blocked.
+func (l *DomainBlocklist) blocked(host string) (string, bool) {
+	for host != "" {
+		if l.domains[host] {
+			return host, true
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+	return "", false
+}
+
+func (l *DomainBlocklist) Check(ctx context.Context, c Content) ([]Finding, error) {
+	var findings []Finding
+	for _, field := range c.Fields {
+		// Full-width letters and dots read as their ASCII forms.
+		text := strings.ToLower(normalizeWidth(field.Text))
+		for _, m := range hostPattern.FindAllStringSubmatch(text, -1) {
+			if domain, ok := l.blocked(m[1]); ok {
+				findings = append(findings, Finding{
+					Rule:     "blocked_domains",
+					Field:    field.Name,
+					Reason:   fmt.Sprintf("links to %s, which is not allowed", domain),
+					Decision: l.decision,
+				})
+				break
+			}
+		}
+	}
+	return findings, nil
+}
+
+// Pattern matches a regular expression against the raw text.
+type Pattern struct {
+	name     string
+	decision Decision
+	re       *regexp.Regexp
+}
+
+// NewPattern returns a rule, called name in findings, deciding decision on
+// text matching expr.
+func NewPattern(name string, decision Decision, expr string) (*Pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", name, err)
+	}
+	return &Pattern{name: name, decision: decision, re: re}, nil
+}
+
+func (p *Pattern) Check(ctx context.Context, c Content) ([]Finding, error) {
+	var findings []Finding
+	for _, field := range c.Fields {
+		if p.re.MatchString(field.Text) {
+			findings = append(findings, Finding{
+				Rule:     p.name,
+				Field:    field.Name,
+				Reason:   "matches " + p.name,
+				Decision: p.decision,
+			})
+		}
+	}
+	return findings, nil
+}
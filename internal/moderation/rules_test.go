@@ -0,0 +1,82 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+)
+
+// check runs rule on text in a single field and reports whether it found
+// anything.
+func check(t *testing.T, rule Rule, text string) bool {
+	t.Helper()
+	findings, err := rule.Check(context.Background(), Content{Kind: "post", Fields: []Field{{Name: "content", Text: text}}})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", text, err)
+	}
+	return len(findings) > 0
+}
+
+func TestWordList(t *testing.T) {
+	rule := NewWordList(Reject, "ass", "Two Words")
+	tests := []struct {
+		text  string
+		match bool
+	}{
+		{"you ass", true},
+		{"ASS!", true},
+		{"a$$", true},
+		{"@ss", true},
+		{"class", false},
+		{"assign the task", false},
+		{"two words", true},
+		{"two   WORDS.", true},
+		{"two-words", true},
+		{"two other words", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := check(t, rule, tt.text); got != tt.match {
+			t.Errorf("%q matched %v, want %v", tt.text, got, tt.match)
+		}
+	}
+}
+
+func TestDomainBlocklist(t *testing.T) {
+	rule := NewDomainBlocklist(Flag, "Spam.Example.")
+	tests := []struct {
+		text  string
+		match bool
+	}{
+		{"see https://spam.example/offer", true},
+		{"mail me at deals@spam.example", true},
+		{"SPAM.EXAMPLE", true},
+		{"http://www.deals.spam.example:8080/", true},
+		{"ｓｐａｍ．ｅｘａｍｐｌｅ", true},
+		{"notspam.example", false},
+		{"spam.example.org", false},
+		{"spam example", false},
+	}
+	for _, tt := range tests {
+		if got := check(t, rule, tt.text); got != tt.match {
+			t.Errorf("%q matched %v, want %v", tt.text, got, tt.match)
+		}
+	}
+}
+
+func TestFilterTakesTheMostSevereDecision(t *testing.T) {
+	filter := New(NewDomainBlocklist(Flag, "spam.example"), NewWordList(Reject, "scam"))
+	result, err := filter.Check(context.Background(), Content{Fields: []Field{
+		{Name: "title", Text: "a scam"},
+		{Name: "content", Text: "at spam.example"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Decision != Reject || len(result.Findings) != 2 {
+		t.Fatalf("result = %+v, want a rejection with two findings", result)
+	}
+	rejections := result.Rejections()
+	if len(rejections) != 1 || rejections["title"] == "" {
+		t.Errorf("rejections = %v, want only the title", rejections)
+	}
+}
@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// RepeatCounter counts what users have saved. Kind is "post" or "comment".
+type RepeatCounter interface {
+	CountRepeats(ctx context.Context, kind string, userID int64, fingerprint []byte, since time.Time) (int, error)
+}
+
+// RepeatedContent catches users posting the same thing over and over. It
+// counts the saved content of the user with the same Fingerprint within a
+// window, so the count holds across API instances and restarts. Content is
+// fingerprinted when it is created, so edits are checked against earlier
+// content but do not count as new content themselves.
+type RepeatedContent struct {
+	counter  RepeatCounter
+	window   time.Duration
+	max      int
+	decision Decision
+	now      func() time.Time
+}
+
+// NewRepeatedContent returns a rule deciding decision once a user posts
+// the same content more than max times within window.
+func NewRepeatedContent(counter RepeatCounter, decision Decision, max int, window time.Duration) *RepeatedContent {
+	return &RepeatedContent{
+		counter:  counter,
+		window:   window,
+		max:      max,
+		decision: decision,
+		now:      time.Now,
+	}
+}
+
+// Fingerprint hashes the normalized text of every field of c, so trivial
+// changes in spacing, case or punctuation do not make content new. It is
+// saved with posts and comments for RepeatedContent to count.
+func Fingerprint(c Content) []byte {
+	h := sha256.New()
+	for _, f := range c.Fields {
+		h.Write([]byte(normalizedPhrase(f.Text)))
+		h.Write([]byte{0})
+	}
+	return h.Sum(nil)
+}
+
+func (s *RepeatedContent) Check(ctx context.Context, c Content) ([]Finding, error) {
+	if len(c.Fields) == 0 {
+		return nil, nil
+	}
+	seen, err := s.counter.CountRepeats(ctx, c.Kind, c.UserID, Fingerprint(c), s.now().Add(-s.window))
+	if err != nil {
+		return nil, err
+	}
+	if seen < s.max {
+		return nil, nil
+	}
+	// The body of the content is its last field.
+	return []Finding{{
+		Rule:     "repeated_content",
+		Field:    c.Fields[len(c.Fields)-1].Name,
+		Reason:   fmt.Sprintf("posted %d times in the last %s", seen+1, s.window),
+		Decision: s.decision,
+	}}, nil
+}
@@ -0,0 +1,109 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeCounter reports count repeats and remembers what it was asked.
+type fakeCounter struct {
+	count       int
+	calls       int
+	kind        string
+	userID      int64
+	fingerprint []byte
+	since       time.Time
+}
+
+func (f *fakeCounter) CountRepeats(ctx context.Context, kind string, userID int64, fingerprint []byte, since time.Time) (int, error) {
+	f.calls++
+	f.kind, f.userID, f.fingerprint, f.since = kind, userID, fingerprint, since
+	return f.count, nil
+}
+
+func TestRepeatedContent(t *testing.T) {
+	now := time.Date(2025, 11, 23, 12, 0, 0, 0, time.UTC)
+	content := Content{Kind: "comment", UserID: 7, Fields: []Field{{Name: "content", Text: "Buy now!"}}}
+	edit := content
+	edit.Edit = true
+
+	tests := []struct {
+		name    string
+		content Content
+		seen    int
+		flagged bool
+	}{
+		{"first time", content, 0, false},
+		{"below the limit", content, 2, false},
+		{"at the limit", content, 3, true},
+		{"past the limit", content, 10, true},
+		{"edit below the limit", edit, 2, false},
+		{"edit at the limit", edit, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &fakeCounter{count: tt.seen}
+			rule := NewRepeatedContent(counter, Flag, 3, 10*time.Minute)
+			rule.now = func() time.Time { return now }
+
+			findings, err := rule.Check(context.Background(), tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flagged := len(findings) > 0; flagged != tt.flagged {
+				t.Fatalf("findings = %+v, want flagged %v", findings, tt.flagged)
+			}
+			if tt.flagged && (findings[0].Decision != Flag || findings[0].Field != "content") {
+				t.Errorf("finding = %+v, want the content flagged", findings[0])
+			}
+			if counter.kind != "comment" || counter.userID != 7 {
+				t.Errorf("counted %s by user %d, want comments by user 7", counter.kind, counter.userID)
+			}
+			if want := now.Add(-10 * time.Minute); !counter.since.Equal(want) {
+				t.Errorf("counted since %v, want %v", counter.since, want)
+			}
+			if !bytes.Equal(counter.fingerprint, Fingerprint(tt.content)) {
+				t.Error("counted a different fingerprint")
+			}
+		})
+	}
+}
+
+func TestRepeatedContentSkipsEmptyContent(t *testing.T) {
+	counter := &fakeCounter{count: 100}
+	findings, err := NewRepeatedContent(counter, Reject, 1, time.Minute).Check(context.Background(), Content{Kind: "post"})
+	if err != nil || len(findings) != 0 || counter.calls != 0 {
+		t.Errorf("findings = %+v, err = %v, calls = %d, want nothing counted", findings, err, counter.calls)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fields := func(texts ...string) Content {
+		c := Content{}
+		for _, text := range texts {
+			c.Fields = append(c.Fields, Field{Text: text})
+		}
+		return c
+	}
+	base := Fingerprint(fields("Hello", "Buy now"))
+	tests := []struct {
+		name    string
+		content Content
+		same    bool
+	}{
+		{"case and spacing", fields("  HELLO ", "buy   NOW!!"), true},
+		{"lookalikes", fields("H3llo", "buy n0w"), true},
+		{"other text", fields("Hello", "Buy later"), false},
+		{"fields split differently", fields("Hello Buy", "now"), false},
+		{"fewer fields", fields("Hello Buy now"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := bytes.Equal(Fingerprint(tt.content), base); same != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const postgresChannel = "societal_stream"
+
+// PostgresBroker fans wake-ups out across API instances through Postgres
+// LISTEN/NOTIFY. Each instance delivers to its own subscribers through an
+// embedded MemoryBroker.
+type PostgresBroker struct {
+	*MemoryBroker
+	db       *sql.DB
+	listener *pq.Listener
+	logger   *zap.SugaredLogger
+}
+
+func NewPostgresBroker(db *sql.DB, addr string, logger *zap.SugaredLogger) (*PostgresBroker, error) {
+	b := &PostgresBroker{
+		MemoryBroker: NewMemoryBroker(),
+		db:           db,
+		logger:       logger,
+	}
+	b.listener = pq.NewListener(addr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warnw("stream listener event", "event", event, "error", err)
+		}
+	})
+	if err := b.listener.Listen(postgresChannel); err != nil {
+		b.listener.Close()
+		return nil, err
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, topics ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for _, topic := range topics {
+		if _, err := b.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, postgresChannel, topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *PostgresBroker) Close() error {
+	return b.listener.Close()
+}
+
+func (b *PostgresBroker) run() {
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// The connection was re-established and notifications
+				// may have been lost while it was down.
+				b.publishAll()
+				continue
+			}
+			b.MemoryBroker.Publish(context.Background(), n.Extra)
+		case <-time.After(90 * time.Second):
+			go func() {
+				if err := b.listener.Ping(); err != nil {
+					b.logger.Warnw("stream listener ping failed", "error", err)
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Broker delivers wake-ups to subscribers of a topic. Messages carry no
+// payload: subscribers react by reading whatever is new from the database,
+// which keeps delivery idempotent and lets a reconnecting client resume from
+// its own cursor.
+type Broker interface {
+	Publish(ctx context.Context, topics ...string) error
+	Subscribe(topics ...string) *Subscription
+}
+
+// Subscription receives a value on C whenever one of its topics is
+// published. Wake-ups that arrive while one is already pending are
+// coalesced, so a slow subscriber never builds up a backlog.
+type Subscription struct {
+	C      <-chan struct{}
+	c      chan struct{}
+	topics []string
+	broker *MemoryBroker
+}
+
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+func (s *Subscription) notify() {
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+}
+
+// MemoryBroker is a Broker for a single process.
+type MemoryBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topics ...string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, topic := range topics {
+		for sub := range b.topics[topic] {
+			sub.notify()
+		}
+	}
+	return nil
+}
+
+// publishAll wakes every subscriber, for when notifications may have been
+// missed.
+func (b *MemoryBroker) publishAll() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, subs := range b.topics {
+		for sub := range subs {
+			sub.notify()
+		}
+	}
+}
+
+func (b *MemoryBroker) Subscribe(topics ...string) *Subscription {
+	c := make(chan struct{}, 1)
+	sub := &Subscription{C: c, c: c, topics: topics, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, topic := range topics {
+		subs, ok := b.topics[topic]
+		if !ok {
+			subs = make(map[*Subscription]struct{})
+			b.topics[topic] = subs
+		}
+		subs[sub] = struct{}{}
+	}
+	return sub
+}
+
+func (b *MemoryBroker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, topic := range sub.topics {
+		subs := b.topics[topic]
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package scheduler publishes scheduled posts when their time comes.
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/jobs"
+	"github.com/Chandan185/Societal/internal/store"
+	"go.uber.org/zap"
+)
+
+// publishBatchSize caps how many posts one PublishDue call publishes.
+const publishBatchSize = 50
+
+// Scheduler polls for scheduled posts that are due and publishes them.
+type Scheduler struct {
+	store     store.Storage
+	logger    *zap.SugaredLogger
+	interval  time.Duration
+	published func(context.Context, *store.Post)
+}
+
+// NewScheduler returns a scheduler that checks for due posts every interval
+// and calls published for each post once it is published.
+func NewScheduler(store store.Storage, logger *zap.SugaredLogger, interval time.Duration, published func(context.Context, *store.Post)) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		logger:    logger,
+		interval:  interval,
+		published: published,
+	}
+}
+
+// Run publishes due posts every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	jobs.RunPeriodic(ctx, s.interval, s.publishDue)
+}
+
+// publishDue publishes a batch of due posts and reports whether more may be
+// due.
+func (s *Scheduler) publishDue(ctx context.Context) bool {
+	posts, err := s.store.Posts.PublishDue(ctx, publishBatchSize)
+	for _, post := range posts {
+		s.published(ctx, post)
+	}
+	if err != nil {
+		s.logger.Errorw("failed to publish scheduled posts", "error", err)
+		return false
+	}
+	return len(posts) == publishBatchSize
+}
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AdminPost is a post as admins see it: drafts, hidden and deleted posts
+// included.
+type AdminPost struct {
+	ID             int64      `json:"id"`
+	UserID         int64      `json:"user_id"`
+	Title          string     `json:"title"`
+	Content        string     `json:"content"`
+	Tags           []string   `json:"tags"`
+	Status         string     `json:"status"`
+	Visibility     string     `json:"visibility"`
+	RepostedPostID *int64     `json:"reposted_post_id"`
+	QuotedPostID   *int64     `json:"quoted_post_id"`
+	Hidden         bool       `json:"hidden"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+}
+
+// AdminComment is a comment as admins see it, hidden or not.
+type AdminComment struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	UserID    int64     `json:"user_id"`
+	Content   string    `json:"content"`
+	Hidden    bool      `json:"hidden"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminFollow is one side of a follow: the follower or the followed user,
+// and when the follow started.
+type AdminFollow struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeletedContent lists what a bulk deletion deleted. Posts and comments
+// that did not exist or were already deleted are left out.
+type DeletedContent struct {
+	PostIDs    []int64 `json:"post_ids"`
+	CommentIDs []int64 `json:"comment_ids"`
+}
+
+// DailyStats counts the users, posts and comments created on a day, in
+// UTC.
+type DailyStats struct {
+	Day      string `json:"day"`
+	Users    int64  `json:"users"`
+	Posts    int64  `json:"posts"`
+	Comments int64  `json:"comments"`
+}
+
+type AdminStore struct {
+	db *sql.DB
+}
+
+// GetUserPosts returns a page of every post userID wrote, newest first.
+func (s *AdminStore) GetUserPosts(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminPost, error) {
+	query := `SELECT id, user_id, title, content, tags, status, visibility, reposted_post_id, quoted_post_id, hidden_at IS NOT NULL, created_at, deleted_at
+	FROM posts
+	WHERE user_id = $1
+	ORDER BY id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, aq.Limit, aq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []AdminPost{}
+	for rows.Next() {
+		var p AdminPost
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &p.Content, pq.Array(&p.Tags), &p.Status, &p.Visibility, &p.RepostedPostID, &p.QuotedPostID, &p.Hidden, &p.CreatedAt, &p.DeletedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+// GetUserComments returns a page of every comment userID wrote, newest
+// first.
+func (s *AdminStore) GetUserComments(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminComment, error) {
+	query := `SELECT id, post_id, user_id, content, hidden_at IS NOT NULL, created_at
+	FROM comments
+	WHERE user_id = $1
+	ORDER BY id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, aq.Limit, aq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []AdminComment{}
+	for rows.Next() {
+		var c AdminComment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.Hidden, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// GetFollowers returns a page of the users following userID, most recent
+// first.
+func (s *AdminStore) GetFollowers(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminFollow, error) {
+	query := `SELECT u.id, u.username, f.created_at
+	FROM followers f
+	JOIN users u ON u.id = f.follower_id
+	WHERE f.user_id = $1
+	ORDER BY f.created_at DESC, u.id DESC
+	LIMIT $2 OFFSET $3`
+	return s.getFollows(ctx, query, userID, aq)
+}
+
+// GetFollowing returns a page of the users userID follows, most recent
+// first.
+func (s *AdminStore) GetFollowing(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminFollow, error) {
+	query := `SELECT u.id, u.username, f.created_at
+	FROM followers f
+	JOIN users u ON u.id = f.user_id
+	WHERE f.follower_id = $1
+	ORDER BY f.created_at DESC, u.id DESC
+	LIMIT $2 OFFSET $3`
+	return s.getFollows(ctx, query, userID, aq)
+}
+
+func (s *AdminStore) getFollows(ctx context.Context, query string, userID int64, aq AdminListQuery) ([]AdminFollow, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, aq.Limit, aq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	follows := []AdminFollow{}
+	for rows.Next() {
+		var f AdminFollow
+		if err := rows.Scan(&f.UserID, &f.Username, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, f)
+	}
+	return follows, rows.Err()
+}
+
+// errNothingDeleted skips auditing posts and comments that were already
+// gone.
+var errNothingDeleted = errors.New("nothing deleted")
+
+// DeleteContent deletes posts and comments in bulk, auditing each. Posts
+// go to their author's trash like any other deleted post, but hidden, so
+// restoring one does not bring it back. Comments are deleted outright.
+func (s *AdminStore) DeleteContent(ctx context.Context, postIDs, commentIDs []int64) (*DeletedContent, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	deleted := &DeletedContent{PostIDs: []int64{}, CommentIDs: []int64{}}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		for _, postID := range postIDs {
+			err := audited(ctx, tx, AuditPostDeleted, AuditTargetPost, "posts", postID, func() error {
+				return deletePost(ctx, tx, postID)
+			})
+			switch {
+			case err == nil:
+				deleted.PostIDs = append(deleted.PostIDs, postID)
+			case !errors.Is(err, errNothingDeleted):
+				return err
+			}
+		}
+		for _, commentID := range commentIDs {
+			err := audited(ctx, tx, AuditCommentDeleted, AuditTargetComment, "comments", commentID, func() error {
+				return deleteComment(ctx, tx, commentID)
+			})
+			switch {
+			case err == nil:
+				deleted.CommentIDs = append(deleted.CommentIDs, commentID)
+			case !errors.Is(err, errNothingDeleted):
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+func deletePost(ctx context.Context, tx *sql.Tx, postID int64) error {
+	query := `UPDATE posts SET deleted_at = NOW(), hidden_at = COALESCE(hidden_at, NOW())
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING user_id, status, version, reposted_post_id, quoted_post_id`
+	post := &Post{ID: postID}
+	err := tx.QueryRowContext(ctx, query, postID).Scan(&post.USERID, &post.Status, &post.Version, &post.RepostedPostID, &post.QuotedPostID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return errNothingDeleted
+		default:
+			return err
+		}
+	}
+	return recordEvent(ctx, tx, EventPostDeleted, postEvent(post))
+}
+
+func deleteComment(ctx context.Context, tx *sql.Tx, commentID int64) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, commentID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errNothingDeleted
+	}
+	return nil
+}
+
+// GetDailyStats counts what was created on each day from sq.From to
+// sq.To, inclusive, in UTC. Each table is counted in one pass over its
+// created_at index, and days on which nothing was created count zero.
+func (s *AdminStore) GetDailyStats(ctx context.Context, sq StatsQuery) ([]DailyStats, error) {
+	query := `WITH days AS (
+		SELECT day::date FROM generate_series($1::date, $2::date, interval '1 day') AS day
+	), bounds AS (
+		SELECT ($1::date)::timestamp AT TIME ZONE 'UTC' AS since, ($2::date + 1)::timestamp AT TIME ZONE 'UTC' AS until
+	), u AS (
+		SELECT (created_at AT TIME ZONE 'UTC')::date AS day, COUNT(*) AS n
+		FROM users, bounds WHERE created_at >= since AND created_at < until GROUP BY 1
+	), p AS (
+		SELECT (created_at AT TIME ZONE 'UTC')::date AS day, COUNT(*) AS n
+		FROM posts, bounds WHERE created_at >= since AND created_at < until GROUP BY 1
+	), c AS (
+		SELECT (created_at AT TIME ZONE 'UTC')::date AS day, COUNT(*) AS n
+		FROM comments, bounds WHERE created_at >= since AND created_at < until GROUP BY 1
+	)
+	SELECT to_char(d.day, 'YYYY-MM-DD'), COALESCE(u.n, 0), COALESCE(p.n, 0), COALESCE(c.n, 0)
+	FROM days d
+	LEFT JOIN u ON u.day = d.day
+	LEFT JOIN p ON p.day = d.day
+	LEFT JOIN c ON c.day = d.day
+	ORDER BY d.day`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, sq.From.Format(time.DateOnly), sq.To.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []DailyStats{}
+	for rows.Next() {
+		var d DailyStats
+		if err := rows.Scan(&d.Day, &d.Users, &d.Posts, &d.Comments); err != nil {
+			return nil, err
+		}
+		stats = append(stats, d)
+	}
+	return stats, rows.Err()
+}
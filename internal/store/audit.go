@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Audited actions.
+const (
+	AuditPostUpdated       = "post.updated"
+	AuditPostDeleted       = "post.deleted"
+	AuditPostRestored      = "post.restored"
+	AuditCommentDeleted    = "comment.deleted"
+	AuditUserUpdated       = "user.updated"
+	AuditUserPrivacy       = "user.privacy_changed"
+	AuditUserRoleChanged   = "user.role_changed"
+	AuditUserPasswordReset = "user.password_reset"
+	AuditUserVerified      = "user.verified"
+	AuditUserUnverified    = "user.unverified"
+	AuditUserDeactivated   = "user.deactivated"
+	AuditUserReactivated   = "user.reactivated"
+	AuditUserSuspended     = "user.suspended"
+	AuditSuspensionLifted  = "user.suspension_lifted"
+	AuditWebhookUpdated    = "webhook.updated"
+	AuditWebhookDeleted    = "webhook.deleted"
+	AuditReportActedOn     = "report.acted_on"
+)
+
+// Targets of audited actions.
+const (
+	AuditTargetPost    = "post"
+	AuditTargetComment = "comment"
+	AuditTargetUser    = "user"
+	AuditTargetWebhook = "webhook"
+	AuditTargetReport  = "report"
+)
+
+// AuditEntry records who changed what, and what it looked like before and
+// after. Before is empty for things created and After for things deleted.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Before     json.RawMessage `json:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" swaggertype:"object"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditInfo identifies who is making changes, and from where.
+type AuditInfo struct {
+	ActorID   *int64
+	RequestID string
+	IP        string
+}
+
+type auditKey struct{}
+
+// WithAuditInfo returns a context under which changes are audited as made
+// as described by info. Changes made without one, by background workers,
+// are audited with no actor.
+func WithAuditInfo(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditKey{}, info)
+}
+
+func auditInfoFrom(ctx context.Context) AuditInfo {
+	info, _ := ctx.Value(auditKey{}).(AuditInfo)
+	return info
+}
+
+// auditSnapshots lists the tables whose rows can be snapshotted, with the
+// columns left out of their snapshots.
+var auditSnapshots = map[string][]string{
+	"posts":       {},
+	"comments":    {},
+	"users":       {"password"},
+	"webhooks":    {"secret"},
+	"reports":     {},
+	"suspensions": {},
+}
+
+// snapshot returns the row of table with the given ID as JSON, locking it
+// for the rest of tx, or nil when there is no such row.
+func snapshot(ctx context.Context, tx *sql.Tx, table string, id int64) (json.RawMessage, error) {
+	omit, ok := auditSnapshots[table]
+	if !ok {
+		return nil, errors.New("store: no audit snapshots of " + table)
+	}
+	query := `SELECT to_jsonb(t) - $2::text[] FROM ` + table + ` t WHERE t.id = $1 FOR UPDATE`
+	var row []byte
+	err := tx.QueryRowContext(ctx, query, id, pq.Array(omit)).Scan(&row)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// audited runs change, which modifies the row of table with the given ID,
+// and audits it with snapshots of the row from before and after. Nothing is
+// audited when change fails.
+func audited(ctx context.Context, tx *sql.Tx, action, targetType, table string, id int64, change func() error) error {
+	before, err := snapshot(ctx, tx, table, id)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	after, err := snapshot(ctx, tx, table, id)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, action, targetType, id, before, after)
+}
+
+// recordAudit appends an entry to the audit log as part of tx.
+func recordAudit(ctx context.Context, tx *sql.Tx, action, targetType string, targetID int64, before, after json.RawMessage) error {
+	info := auditInfoFrom(ctx)
+	query := `INSERT INTO audit_log (actor_id, action, target_type, target_id, before, after, request_id, ip)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.ExecContext(ctx, query, info.ActorID, action, targetType, targetID, nullJSON(before), nullJSON(after), info.RequestID, info.IP)
+	return err
+}
+
+// nullJSON stores missing snapshots as NULL.
+func nullJSON(data json.RawMessage) any {
+	if data == nil {
+		return nil
+	}
+	return []byte(data)
+}
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+// Get returns a page of the audit log matching aq, newest first. Pages
+// continue from the ID of the last entry of the previous page, passed as
+// aq.Before.
+func (s *AuditStore) Get(ctx context.Context, aq AuditQuery) ([]AuditEntry, error) {
+	query := `SELECT id, actor_id, action, target_type, target_id, before, after, request_id, ip, created_at
+	FROM audit_log
+	WHERE ($1 = 0 OR actor_id = $1) AND
+		($2 = '' OR target_type = $2) AND
+		($3 = 0 OR target_id = $3) AND
+		($4 = '' OR action = $4) AND
+		($5::timestamptz IS NULL OR created_at >= $5) AND
+		($6::timestamptz IS NULL OR created_at < $6) AND
+		($7 = 0 OR id < $7)
+	ORDER BY id DESC
+	LIMIT $8`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, aq.ActorID, aq.TargetType, aq.TargetID, aq.Action, aq.Since, aq.Until, aq.Before, aq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &before, &after, &e.RequestID, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Block struct {
+	BlockerID int64     `json:"blocker_id"`
+	BlockedID int64     `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BlockStore struct {
+	db *sql.DB
+}
+
+// Block records that blockerID blocked blockedID and removes any follow
+// relationship or pending follow request between the two users in either
+// direction.
+func (s *BlockStore) Block(ctx context.Context, blockerID, blockedID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+		query = `DELETE FROM follow_requests WHERE (user_id=$1 AND requester_id=$2) OR (user_id=$2 AND requester_id=$1)`
+		if _, err := tx.ExecContext(ctx, query, blockerID, blockedID); err != nil {
+			return err
+		}
+		query = `DELETE FROM followers WHERE (user_id=$1 AND follower_id=$2) OR (user_id=$2 AND follower_id=$1)
+		RETURNING user_id, follower_id`
+		rows, err := tx.QueryContext(ctx, query, blockerID, blockedID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		unfollowed := []FollowEvent{}
+		for rows.Next() {
+			var e FollowEvent
+			if err := rows.Scan(&e.UserID, &e.FollowerID); err != nil {
+				return err
+			}
+			unfollowed = append(unfollowed, e)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, e := range unfollowed {
+			if err := recordEvent(ctx, tx, EventUserUnfollowed, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BlockStore) Unblock(ctx context.Context, blockerID, blockedID int64) error {
+	query := `DELETE FROM blocks WHERE blocker_id=$1 AND blocked_id=$2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Bookmark struct {
+	ID           int64            `json:"id"`
+	CollectionID *int64           `json:"collection_id"`
+	CreatedAt    time.Time        `json:"created_at"`
+	Post         PostWithMetadata `json:"post"`
+}
+
+type BookmarkCollection struct {
+	ID            int64     `json:"id"`
+	UserID        int64     `json:"user_id"`
+	Name          string    `json:"name"`
+	BookmarkCount int64     `json:"bookmark_count"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+// Save bookmarks a post for userID, or moves an existing bookmark, into
+// collectionID when it is not nil. It returns ErrNotFound when the
+// collection does not belong to userID.
+func (s *BookmarkStore) Save(ctx context.Context, userID, postID int64, collectionID *int64) error {
+	query := `INSERT INTO bookmarks (user_id, post_id, collection_id)
+	SELECT $1, $2, $3::bigint
+	WHERE $3::bigint IS NULL OR EXISTS (SELECT 1 FROM bookmark_collections WHERE id = $3 AND user_id = $1)
+	ON CONFLICT (user_id, post_id) DO UPDATE SET collection_id = EXCLUDED.collection_id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, query, userID, postID, collectionID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *BookmarkStore) Remove(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	return err
+}
+
+func (s *BookmarkStore) IsBookmarked(ctx context.Context, userID, postID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM bookmarks WHERE user_id = $1 AND post_id = $2)`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var bookmarked bool
+	err := s.db.QueryRowContext(ctx, query, userID, postID).Scan(&bookmarked)
+	return bookmarked, err
+}
+
+// Get returns a page of userID's bookmarks, newest first, leaving out posts
+// they can no longer see. Pages continue from the ID of the last bookmark of
+// the previous page, passed as bq.Before.
+func (s *BookmarkStore) Get(ctx context.Context, userID int64, bq BookmarkQuery) ([]Bookmark, error) {
+	query := `SELECT b.id, b.collection_id, b.created_at,
+		p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.visibility, u.username,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id),
+		p.reposted_post_id, p.quoted_post_id, ` + shareCounts + `, p.status
+	FROM bookmarks b
+	JOIN posts p ON p.id = b.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE b.user_id = $1 AND
+		($2 = 0 OR b.id < $2) AND
+		($3 = 0 OR b.collection_id = $3) AND
+		` + visibleTo("$1") + `
+	ORDER BY b.id DESC
+	LIMIT $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, bq.Before, bq.CollectionID, bq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		var b Bookmark
+		post := &b.Post
+		err := rows.Scan(&b.ID, &b.CollectionID, &b.CreatedAt,
+			&post.ID, &post.USERID, &post.Title, &post.Content, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt, &post.Version, &post.Visibility, &post.User.Username, &post.CommentCount,
+			&post.RepostedPostID, &post.QuotedPostID, &post.RepostCount, &post.QuoteCount, &post.Status)
+		if err != nil {
+			return nil, err
+		}
+		post.Bookmarked = true
+		post.Edited = post.Version > 0
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, len(bookmarks))
+	for i := range bookmarks {
+		posts[i] = &bookmarks[i].Post.Post
+	}
+	if err := loadPostMentions(ctx, s.db, posts); err != nil {
+		return nil, err
+	}
+	if err := loadEmbeddedPosts(ctx, s.db, userID, posts); err != nil {
+		return nil, err
+	}
+	return bookmarks, nil
+}
+
+// CreateCollection adds a named collection for collection.UserID. It
+// returns ErrConflict when they already have one with that name.
+func (s *BookmarkStore) CreateCollection(ctx context.Context, collection *BookmarkCollection) error {
+	query := `INSERT INTO bookmark_collections (user_id, name) VALUES ($1, $2) RETURNING id, created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := s.db.QueryRowContext(ctx, query, collection.UserID, collection.Name).Scan(&collection.ID, &collection.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetCollections returns userID's collections in alphabetical order. Like
+// Get, their bookmark counts leave out posts userID can no longer see.
+func (s *BookmarkStore) GetCollections(ctx context.Context, userID int64) ([]BookmarkCollection, error) {
+	query := `SELECT bc.id, bc.user_id, bc.name, bc.created_at,
+		(SELECT COUNT(*) FROM bookmarks b
+			JOIN posts p ON p.id = b.post_id
+			JOIN users u ON u.id = p.user_id
+			WHERE b.collection_id = bc.id AND ` + visibleTo("$1") + `)
+	FROM bookmark_collections bc
+	WHERE bc.user_id = $1
+	ORDER BY bc.name`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []BookmarkCollection{}
+	for rows.Next() {
+		var c BookmarkCollection
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CreatedAt, &c.BookmarkCount); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// RenameCollection renames one of collection.UserID's collections. It
+// returns ErrNotFound when they have no such collection and ErrConflict when
+// the name is taken.
+func (s *BookmarkStore) RenameCollection(ctx context.Context, collection *BookmarkCollection) error {
+	query := `UPDATE bookmark_collections SET name = $1 WHERE id = $2 AND user_id = $3 RETURNING created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := s.db.QueryRowContext(ctx, query, collection.Name, collection.ID, collection.UserID).Scan(&collection.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCollection removes one of userID's collections. Its bookmarks are
+// kept, outside any collection.
+func (s *BookmarkStore) DeleteCollection(ctx context.Context, userID, collectionID int64) error {
+	query := `DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, query, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Comment struct {
@@ -13,39 +15,139 @@ type Comment struct {
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	User      User      `json:"user"`
+	Mentions  []Mention `json:"mentions"`
+	// TxID is the transaction that created the comment, for streaming.
+	TxID int64 `json:"-"`
+	// FlagDetails, when set, files an automated report on the comment in
+	// the transaction that saves it, for content the filter flagged.
+	FlagDetails string `json:"-"`
+	// ContentFingerprint identifies the comment's content, for the content
+	// filter to count repeats by.
+	ContentFingerprint []byte `json:"-"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, PostID int64) ([]Comment, error) {
-	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id FROM comments as c JOIN users ON c.user_id=users.id WHERE c.post_id = $1 ORDER BY c.created_at DESC`
+// GetByPostID returns the comments on a post, leaving out comments by users
+// that viewerID has blocked, muted or been blocked by, and hidden comments
+// by anyone else.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error) {
+	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id, c.txid FROM comments as c JOIN users ON c.user_id=users.id
+	WHERE c.post_id = $1 AND (c.hidden_at IS NULL OR c.user_id = $2) AND
+		NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id) OR (b.blocker_id = c.user_id AND b.blocked_id = $2)) AND
+		NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = $2 AND m.muted_id = c.user_id)
+	ORDER BY c.created_at DESC`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return scanComments(ctx, s.db, rows)
+}
+
+// GetSince returns up to limit comments on the given posts after pos,
+// oldest first, filtered for viewerID like GetByPostID. Comments on posts
+// viewerID can no longer see are left out, so a post that is deleted,
+// hidden or restricted stops streaming comments.
+func (s *CommentStore) GetSince(ctx context.Context, postIDs []int64, viewerID int64, pos Position, limit int) ([]Comment, error) {
+	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id, c.txid FROM comments as c JOIN users ON c.user_id=users.id
+	JOIN posts p ON p.id = c.post_id
+	JOIN users u ON u.id = p.user_id
+	WHERE c.post_id = ANY($1) AND ` + committedAfter("c.txid", "c.id", "$3", "$4") + ` AND (c.hidden_at IS NULL OR c.user_id = $2) AND
+		` + visibleTo("$2") + ` AND
+		NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id) OR (b.blocker_id = c.user_id AND b.blocked_id = $2)) AND
+		NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = $2 AND m.muted_id = c.user_id)
+	ORDER BY ` + byCommit("c.txid", "c.id") + `
+	LIMIT $5`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	rows, err := s.db.QueryContext(ctx, query, PostID)
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs), viewerID, pos.TxID, pos.ID, limit)
 	if err != nil {
 		return nil, err
 	}
+	return scanComments(ctx, s.db, rows)
+}
+
+func scanComments(ctx context.Context, q queryer, rows *sql.Rows) ([]Comment, error) {
 	defer rows.Close()
 
 	comments := []Comment{}
 	for rows.Next() {
 		var c Comment
 		c.User = User{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID)
+		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID, &c.TxID)
 		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ptrs := make([]*Comment, len(comments))
+	for i := range comments {
+		ptrs[i] = &comments[i]
+	}
+	if err := loadCommentMentions(ctx, q, ptrs); err != nil {
+		return nil, err
+	}
 	return comments, nil
 }
 
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
-	query := `INSERT INTO comments (post_id, user_id, content) VALUES ($1, $2, $3) RETURNING id, created_at`
+	query := `INSERT INTO comments (post_id, user_id, content, content_fingerprint) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content).Scan(&comment.ID, &comment.CreatedAt)
-	return err
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.Content, comment.ContentFingerprint).Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			return err
+		}
+		comment.Mentions, err = replaceMentions(ctx, tx, comment.PostID, &comment.ID, comment.UserID, comment.Content)
+		if err != nil {
+			return err
+		}
+		var authorID int64
+		if err := tx.QueryRowContext(ctx, `SELECT user_id FROM posts WHERE id = $1`, comment.PostID).Scan(&authorID); err != nil {
+			return err
+		}
+		if err := notifyPostAuthor(ctx, tx, comment, authorID); err != nil {
+			return err
+		}
+		if err := flagContent(ctx, tx, ReportTargetComment, comment.ID, comment.UserID, comment.FlagDetails); err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, EventCommentCreated, CommentEvent{
+			CommentID:    comment.ID,
+			PostID:       comment.PostID,
+			UserID:       comment.UserID,
+			PostAuthorID: authorID,
+		})
+	})
+}
+
+// notifyPostAuthor tells authorID, the author of the post, about a new
+// comment, unless they wrote it or were already notified of being mentioned
+// in it.
+func notifyPostAuthor(ctx context.Context, tx *sql.Tx, comment *Comment, authorID int64) error {
+	if authorID == comment.UserID {
+		return nil
+	}
+	for _, m := range comment.Mentions {
+		if m.UserID == authorID {
+			return nil
+		}
+	}
+	return createNotification(ctx, tx, &Notification{
+		UserID:    authorID,
+		ActorID:   comment.UserID,
+		Type:      NotificationComment,
+		PostID:    &comment.PostID,
+		CommentID: &comment.ID,
+	})
 }
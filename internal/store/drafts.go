@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// GetDrafts returns a page of userID's unpublished posts, optionally only
+// those with dq.Status: scheduled posts in the order they will be published,
+// then drafts, most recently edited first.
+func (p *PostStore) GetDrafts(ctx context.Context, userID int64, dq DraftQuery) ([]Post, error) {
+	query := `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.visibility, u.username,
+		p.quoted_post_id, p.status, p.publish_at
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.user_id = $1 AND p.status <> 'published' AND p.deleted_at IS NULL AND
+		($4 = '' OR p.status = $4)
+	ORDER BY p.publish_at NULLS LAST, p.updated_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := p.db.QueryContext(ctx, query, userID, dq.Limit, dq.Offset, dq.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []Post{}
+	for rows.Next() {
+		var d Post
+		err := rows.Scan(&d.ID, &d.USERID, &d.Title, &d.Content, pq.Array(&d.Tags), &d.CreatedAt, &d.UpdatedAt, &d.Version, &d.Visibility, &d.User.Username,
+			&d.QuotedPostID, &d.Status, &d.PublishAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Edited = d.Version > 0
+		drafts = append(drafts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, len(drafts))
+	for i := range drafts {
+		posts[i] = &drafts[i]
+	}
+	if err := loadPostMentions(ctx, p.db, posts); err != nil {
+		return nil, err
+	}
+	if err := loadEmbeddedPosts(ctx, p.db, userID, posts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// PublishDue publishes up to limit scheduled posts whose time has come and
+// returns them, along with the posts published before any error. Each post
+// is claimed with SKIP LOCKED and published in a short transaction of its
+// own, so concurrent callers never publish the same post twice and a batch
+// never holds back the streams, which wait for every older transaction to
+// finish.
+func (p *PostStore) PublishDue(ctx context.Context, limit int) ([]*Post, error) {
+	posts := []*Post{}
+	for len(posts) < limit {
+		post, err := p.publishNextDue(ctx)
+		if err != nil {
+			return posts, err
+		}
+		if post == nil {
+			break
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// publishNextDue publishes the scheduled post that has been due the
+// longest, or returns nil when none is.
+func (p *PostStore) publishNextDue(ctx context.Context) (*Post, error) {
+	query := `SELECT id FROM posts
+	WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+	ORDER BY publish_at
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var post *Post
+	err := withTx(p.db, ctx, func(tx *sql.Tx) error {
+		due := &Post{}
+		if err := tx.QueryRowContext(ctx, query).Scan(&due.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if err := publishPost(ctx, tx, due); err != nil {
+			return err
+		}
+		post = due
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// publishPost publishes the draft or scheduled post with post.ID as if it
+// had just been created: it moves to the top of feeds, its edit history
+// starts over and the users it mentions or quotes are notified.
+func publishPost(ctx context.Context, tx *sql.Tx, post *Post) error {
+	query := `UPDATE posts SET status = 'published', publish_at = NULL, published_seq = nextval('post_publish_seq'),
+		published_txid = txid_current(), created_at = NOW(), updated_at = NOW(), version = 0
+	WHERE id = $1 AND status <> 'published'
+	RETURNING user_id, quoted_post_id, created_at, updated_at, published_seq, published_txid`
+	err := tx.QueryRowContext(ctx, query, post.ID).Scan(&post.USERID, &post.QuotedPostID, &post.CreatedAt, &post.UpdatedAt, &post.PublishedSeq, &post.PublishedTxID)
+	if err != nil {
+		return err
+	}
+	post.Status = PostStatusPublished
+	post.PublishAt = nil
+	post.Version = 0
+	post.Edited = false
+
+	if err := loadPostMentions(ctx, tx, []*Post{post}); err != nil {
+		return err
+	}
+	mentioned := make([]int64, len(post.Mentions))
+	for i, m := range post.Mentions {
+		mentioned[i] = m.UserID
+	}
+	if err := notifyMentioned(ctx, tx, post.ID, nil, post.USERID, mentioned); err != nil {
+		return err
+	}
+	if post.QuotedPostID != nil {
+		if err := notifyQuotedAuthor(ctx, tx, post); err != nil {
+			return err
+		}
+	}
+	return recordEvent(ctx, tx, EventPostPublished, postEvent(post))
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Chandan185/Societal/internal/events"
+)
+
+// Domain events recorded in the outbox by the writes they describe.
+const (
+	// EventPostCreated is recorded for every new post, including drafts,
+	// scheduled posts and reposts. Its payload is a PostEvent.
+	EventPostCreated = "post.created"
+	// EventPostPublished is recorded when a post that is not a repost goes
+	// live, whether it was created published or published later.
+	EventPostPublished = "post.published"
+	EventPostUpdated   = "post.updated"
+	// EventPostDeleted is recorded when a post is moved to the trash or a
+	// repost is undone.
+	EventPostDeleted  = "post.deleted"
+	EventPostRestored = "post.restored"
+	// EventPostPurged is recorded when a deleted post is removed for good.
+	EventPostPurged = "post.purged"
+	// EventCommentCreated carries a CommentEvent.
+	EventCommentCreated = "comment.created"
+	// EventUserFollowed and EventUserUnfollowed carry a FollowEvent.
+	EventUserFollowed   = "user.followed"
+	EventUserUnfollowed = "user.unfollowed"
+)
+
+type PostEvent struct {
+	PostID         int64  `json:"post_id"`
+	UserID         int64  `json:"user_id"`
+	Status         string `json:"status,omitempty"`
+	Version        int64  `json:"version"`
+	RepostedPostID *int64 `json:"reposted_post_id,omitempty"`
+	QuotedPostID   *int64 `json:"quoted_post_id,omitempty"`
+}
+
+type CommentEvent struct {
+	CommentID    int64 `json:"comment_id"`
+	PostID       int64 `json:"post_id"`
+	UserID       int64 `json:"user_id"`
+	PostAuthorID int64 `json:"post_author_id"`
+}
+
+type FollowEvent struct {
+	FollowerID int64 `json:"follower_id"`
+	UserID     int64 `json:"user_id"`
+}
+
+func postEvent(post *Post) PostEvent {
+	return PostEvent{
+		PostID:         post.ID,
+		UserID:         post.USERID,
+		Status:         post.Status,
+		Version:        post.Version,
+		RepostedPostID: post.RepostedPostID,
+		QuotedPostID:   post.QuotedPostID,
+	}
+}
+
+func recordEvent(ctx context.Context, tx *sql.Tx, eventType string, payload any) error {
+	return events.Record(ctx, tx, eventType, payload)
+}
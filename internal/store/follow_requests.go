@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type FollowRequest struct {
+	RequesterID int64     `json:"requester_id"`
+	UserID      int64     `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Requester   User      `json:"requester"`
+}
+
+type FollowRequestStore struct {
+	db *sql.DB
+}
+
+// Create records that requesterID asked to follow the private account
+// userID. It returns ErrBlocked when either user has blocked the other and
+// ErrConflict when a request is already pending.
+func (s *FollowRequestStore) Create(ctx context.Context, req *FollowRequest) error {
+	query := `INSERT INTO follow_requests (requester_id, user_id)
+	SELECT $1, $2
+	WHERE NOT EXISTS (SELECT 1 FROM blocks WHERE (blocker_id=$1 AND blocked_id=$2) OR (blocker_id=$2 AND blocked_id=$1))
+	RETURNING created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, req.RequesterID, req.UserID).Scan(&req.CreatedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrBlocked
+			default:
+				return err
+			}
+		}
+		return createNotification(ctx, tx, &Notification{
+			UserID:  req.UserID,
+			ActorID: req.RequesterID,
+			Type:    NotificationFollowRequest,
+		})
+	})
+}
+
+// GetPending returns the follow requests waiting on userID, newest first.
+func (s *FollowRequestStore) GetPending(ctx context.Context, userID int64) ([]FollowRequest, error) {
+	query := `SELECT fr.requester_id, fr.user_id, fr.created_at, u.id, u.username
+	FROM follow_requests fr
+	JOIN users u ON u.id = fr.requester_id
+	WHERE fr.user_id = $1
+	ORDER BY fr.created_at DESC`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []FollowRequest{}
+	for rows.Next() {
+		var fr FollowRequest
+		if err := rows.Scan(&fr.RequesterID, &fr.UserID, &fr.CreatedAt, &fr.Requester.ID, &fr.Requester.Username); err != nil {
+			return nil, err
+		}
+		requests = append(requests, fr)
+	}
+	return requests, nil
+}
+
+// Approve turns the pending request from requesterID into a follower row and
+// lets the requester know. It returns ErrBlocked when either user has
+// blocked the other.
+func (s *FollowRequestStore) Approve(ctx context.Context, userID, requesterID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteFollowRequest(ctx, tx, userID, requesterID); err != nil {
+			return err
+		}
+		query := `INSERT INTO followers (user_id, follower_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM blocks WHERE (blocker_id=$1 AND blocked_id=$2) OR (blocker_id=$2 AND blocked_id=$1))
+		ON CONFLICT DO NOTHING`
+		res, err := tx.ExecContext(ctx, query, userID, requesterID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			// Either the requester already follows userID, or one of them
+			// blocked the other.
+			var blocked bool
+			query = `SELECT EXISTS (SELECT 1 FROM blocks WHERE (blocker_id=$1 AND blocked_id=$2) OR (blocker_id=$2 AND blocked_id=$1))`
+			if err := tx.QueryRowContext(ctx, query, userID, requesterID).Scan(&blocked); err != nil {
+				return err
+			}
+			if blocked {
+				return ErrBlocked
+			}
+		} else if err := recordEvent(ctx, tx, EventUserFollowed, FollowEvent{FollowerID: requesterID, UserID: userID}); err != nil {
+			return err
+		}
+		return createNotification(ctx, tx, &Notification{
+			UserID:  requesterID,
+			ActorID: userID,
+			Type:    NotificationFollowAccepted,
+		})
+	})
+}
+
+// Reject discards the pending request from requesterID.
+func (s *FollowRequestStore) Reject(ctx context.Context, userID, requesterID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteFollowRequest(ctx, tx, userID, requesterID)
+	})
+}
+
+func deleteFollowRequest(ctx context.Context, tx *sql.Tx, userID, requesterID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id=$1 AND requester_id=$2`
+	res, err := tx.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -18,23 +18,86 @@ type FollowerStore struct {
 	db *sql.DB
 }
 
+// Follow makes followerID follow userID and notifies userID. It returns
+// ErrBlocked when either user has blocked the other.
 func (s *FollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
-	query := `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)`
+	query := `INSERT INTO followers (user_id, follower_id)
+	SELECT $1, $2
+	WHERE NOT EXISTS (SELECT 1 FROM blocks WHERE (blocker_id=$1 AND blocked_id=$2) OR (blocker_id=$2 AND blocked_id=$1))`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return ErrConflict
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
 		}
-	}
-	return err
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrBlocked
+		}
+		err = createNotification(ctx, tx, &Notification{
+			UserID:  userID,
+			ActorID: followerID,
+			Type:    NotificationFollow,
+		})
+		if err != nil {
+			return err
+		}
+		return recordEvent(ctx, tx, EventUserFollowed, FollowEvent{FollowerID: followerID, UserID: userID})
+	})
 }
 
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
 	query := `DELETE FROM followers WHERE user_id=$1 AND follower_id=$2`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
-	return err
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query, userID, followerID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+		return recordEvent(ctx, tx, EventUserUnfollowed, FollowEvent{FollowerID: followerID, UserID: userID})
+	})
+}
+
+// IsFollowing reports whether followerID follows userID.
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id=$1 AND follower_id=$2)`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var following bool
+	err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&following)
+	return following, err
+}
+
+// GetFollowingIDs returns the IDs of the users followerID follows.
+func (s *FollowerStore) GetFollowingIDs(ctx context.Context, followerID int64) ([]int64, error) {
+	query := `SELECT user_id FROM followers WHERE follower_id=$1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, followerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
 }
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	MediaStatusPending    = "pending"
+	MediaStatusProcessing = "processing"
+	MediaStatusReady      = "ready"
+	MediaStatusFailed     = "failed"
+)
+
+// mediaProcessingTimeout is how long media may stay in processing before
+// another worker assumes the first one died and claims it again.
+const mediaProcessingTimeout = 5 * time.Minute
+
+// ErrClaimLost is returned to a worker whose claim on media was taken over
+// by another worker, which makes the other worker's result the one kept.
+var ErrClaimLost = errors.New("media was claimed again by another worker")
+
+type Media struct {
+	ID          int64          `json:"id"`
+	UserID      int64          `json:"user_id"`
+	PostID      *int64         `json:"post_id"`
+	StorageKey  string         `json:"-"`
+	ContentType string         `json:"content_type"`
+	SizeBytes   int64          `json:"size_bytes"`
+	Status      string         `json:"status"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Placeholder string         `json:"placeholder"`
+	Variants    []MediaVariant `json:"variants"`
+	CreatedAt   time.Time      `json:"created_at"`
+	// ClaimedAt is when ClaimPending claimed the media for processing. It
+	// identifies the claim to MarkReady and MarkFailed.
+	ClaimedAt time.Time `json:"-"`
+}
+
+type MediaVariant struct {
+	Name        string `json:"name"`
+	StorageKey  string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	SizeBytes   int64  `json:"size_bytes"`
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+const mediaColumns = `id, user_id, post_id, storage_key, content_type, size_bytes, status, width, height, placeholder, created_at`
+
+func scanMedia(row interface{ Scan(...any) error }, m *Media) error {
+	return row.Scan(&m.ID, &m.UserID, &m.PostID, &m.StorageKey, &m.ContentType, &m.SizeBytes, &m.Status, &m.Width, &m.Height, &m.Placeholder, &m.CreatedAt)
+}
+
+func (s *MediaStore) Create(ctx context.Context, media *Media) error {
+	query := `INSERT INTO media (user_id, storage_key, content_type, size_bytes, status) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	media.Variants = []MediaVariant{}
+	return s.db.QueryRowContext(ctx, query, media.UserID, media.StorageKey, media.ContentType, media.SizeBytes, media.Status).Scan(&media.ID, &media.CreatedAt)
+}
+
+func (s *MediaStore) GetByID(ctx context.Context, id int64) (*Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var m Media
+	if err := scanMedia(s.db.QueryRowContext(ctx, query, id), &m); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	if err := loadVariants(ctx, s.db, []*Media{&m}); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetByPostID returns the processed media attached to a post, in order.
+func (s *MediaStore) GetByPostID(ctx context.Context, postID int64) ([]Media, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return getPostMedia(ctx, s.db, postID)
+}
+
+// ClaimPending marks the oldest unprocessed media as processing and returns
+// it, or ErrNotFound when there is nothing to do. Media left in processing by
+// a worker that died is claimed again after mediaProcessingTimeout.
+func (s *MediaStore) ClaimPending(ctx context.Context) (*Media, error) {
+	query := `UPDATE media SET status = 'processing', processing_started_at = NOW()
+	WHERE id = (
+		SELECT id FROM media
+		WHERE status = 'pending' OR (status = 'processing' AND processing_started_at < NOW() - $1 * interval '1 second')
+		ORDER BY id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING ` + mediaColumns + `, processing_started_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var m Media
+	row := s.db.QueryRowContext(ctx, query, mediaProcessingTimeout.Seconds())
+	err := row.Scan(&m.ID, &m.UserID, &m.PostID, &m.StorageKey, &m.ContentType, &m.SizeBytes, &m.Status, &m.Width, &m.Height, &m.Placeholder, &m.CreatedAt, &m.ClaimedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+// MarkReady stores the result of processing media, replacing its variants.
+// It returns ErrClaimLost, and changes nothing, when the claim media.ClaimedAt
+// identifies is no longer the media's current claim.
+func (s *MediaStore) MarkReady(ctx context.Context, media *Media) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE media SET status = 'ready', storage_key = $1, content_type = $2, size_bytes = $3, width = $4, height = $5, placeholder = $6, processing_started_at = NULL
+		WHERE id = $7 AND status = 'processing' AND processing_started_at = $8`
+		res, err := tx.ExecContext(ctx, query, media.StorageKey, media.ContentType, media.SizeBytes, media.Width, media.Height, media.Placeholder, media.ID, media.ClaimedAt)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrClaimLost
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM media_variants WHERE media_id = $1`, media.ID); err != nil {
+			return err
+		}
+		query = `INSERT INTO media_variants (media_id, name, storage_key, content_type, width, height, size_bytes) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		for _, v := range media.Variants {
+			if _, err := tx.ExecContext(ctx, query, media.ID, v.Name, v.StorageKey, v.ContentType, v.Width, v.Height, v.SizeBytes); err != nil {
+				return err
+			}
+		}
+		media.Status = MediaStatusReady
+		return nil
+	})
+}
+
+// MarkFailed records that media could not be processed. Like MarkReady, it
+// returns ErrClaimLost when media's claim is no longer current.
+func (s *MediaStore) MarkFailed(ctx context.Context, media *Media) error {
+	query := `UPDATE media SET status = 'failed', processing_started_at = NULL
+	WHERE id = $1 AND status = 'processing' AND processing_started_at = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, query, media.ID, media.ClaimedAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrClaimLost
+	}
+	media.Status = MediaStatusFailed
+	return nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getPostMedia(ctx context.Context, q queryer, postID int64) ([]Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE post_id = $1 AND status = 'ready' ORDER BY position`
+	rows, err := q.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []Media{}
+	for rows.Next() {
+		var m Media
+		if err := scanMedia(rows, &m); err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ptrs := make([]*Media, len(media))
+	for i := range media {
+		ptrs[i] = &media[i]
+	}
+	return media, loadVariants(ctx, q, ptrs)
+}
+
+func loadVariants(ctx context.Context, q queryer, media []*Media) error {
+	byID := make(map[int64]*Media, len(media))
+	ids := make([]int64, len(media))
+	for i, m := range media {
+		m.Variants = []MediaVariant{}
+		byID[m.ID] = m
+		ids[i] = m.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `SELECT media_id, name, storage_key, content_type, width, height, size_bytes FROM media_variants WHERE media_id = ANY($1) ORDER BY media_id, width`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mediaID int64
+		var v MediaVariant
+		if err := rows.Scan(&mediaID, &v.Name, &v.StorageKey, &v.ContentType, &v.Width, &v.Height, &v.SizeBytes); err != nil {
+			return err
+		}
+		m := byID[mediaID]
+		m.Variants = append(m.Variants, v)
+	}
+	return rows.Err()
+}
+
+// attachPostMedia links the media in post.MediaIDs to post, in order, and
+// loads the processed ones into post.Media. Every item must belong to the
+// post's author and not be attached to another post, otherwise
+// ErrInvalidMedia is returned.
+func attachPostMedia(ctx context.Context, tx *sql.Tx, post *Post) error {
+	post.Media = []Media{}
+	if len(post.MediaIDs) == 0 {
+		return nil
+	}
+	query := `UPDATE media SET post_id = $1, position = m.position
+	FROM unnest($2::bigint[]) WITH ORDINALITY AS m(id, position)
+	WHERE media.id = m.id AND media.user_id = $3 AND media.post_id IS NULL AND media.status <> 'failed'`
+	res, err := tx.ExecContext(ctx, query, post.ID, pq.Array(post.MediaIDs), post.USERID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(post.MediaIDs)) {
+		return ErrInvalidMedia
+	}
+	post.Media, err = getPostMedia(ctx, tx, post.ID)
+	return err
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// maxMentions caps how many distinct users one post or comment can mention,
+// so a single write cannot fan out notifications to everyone.
+const maxMentions = 10
+
+// mentionPattern matches @username when the @ is not part of a longer word,
+// so email addresses are not treated as mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// Mention is a resolved @username in a post or comment. Start and End are
+// offsets in Unicode code points covering the whole "@username" text.
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+type mentionMatch struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// parseMentions returns every @username in content with its code point
+// offsets.
+func parseMentions(content string) []mentionMatch {
+	matches := []mentionMatch{}
+	for _, idx := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		at := idx[2] - 1
+		start := utf8.RuneCountInString(content[:at])
+		username := content[idx[2]:idx[3]]
+		matches = append(matches, mentionMatch{
+			Username: username,
+			Start:    start,
+			End:      start + 1 + utf8.RuneCountInString(username),
+		})
+	}
+	return matches
+}
+
+// replaceMentions resolves the users mentioned in content and stores them as
+// the mentions of a post (commentID nil) or of a comment on it, replacing any
+// previous set. Users mentioned for the first time are notified unless they
+// have blocked authorID or cannot see the post, so nobody is notified about
+// mentions in drafts.
+func replaceMentions(ctx context.Context, tx *sql.Tx, postID int64, commentID *int64, authorID int64, content string) ([]Mention, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT user_id FROM mentions WHERE post_id=$1 AND comment_id IS NOT DISTINCT FROM $2`, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	previous := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		previous[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mentions WHERE post_id=$1 AND comment_id IS NOT DISTINCT FROM $2`, postID, commentID); err != nil {
+		return nil, err
+	}
+
+	matches := parseMentions(content)
+	mentions := []Mention{}
+	if len(matches) == 0 {
+		return mentions, nil
+	}
+
+	usernames := []string{}
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if !seen[m.Username] && len(usernames) < maxMentions {
+			seen[m.Username] = true
+			usernames = append(usernames, m.Username)
+		}
+	}
+	rows, err = tx.QueryContext(ctx, `SELECT id, username FROM users WHERE username = ANY($1)`, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	userIDs := map[string]int64{}
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs[username] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	insert := `INSERT INTO mentions (post_id, comment_id, user_id, start_offset, end_offset) VALUES ($1, $2, $3, $4, $5)`
+	added := []int64{}
+	for _, m := range matches {
+		userID, ok := userIDs[m.Username]
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, insert, postID, commentID, userID, m.Start, m.End); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, Mention{UserID: userID, Username: m.Username, Start: m.Start, End: m.End})
+		if !previous[userID] {
+			added = append(added, userID)
+		}
+	}
+	if err := notifyMentioned(ctx, tx, postID, commentID, authorID, added); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+// notifyMentioned tells each of userIDs, other than authorID, that they were
+// mentioned in a post or a comment on it. Users who cannot see the post are
+// skipped.
+func notifyMentioned(ctx context.Context, tx *sql.Tx, postID int64, commentID *int64, authorID int64, userIDs []int64) error {
+	notified := map[int64]bool{}
+	for _, userID := range userIDs {
+		if userID == authorID || notified[userID] {
+			continue
+		}
+		notified[userID] = true
+		// Mentioning someone must not reveal a post they cannot open.
+		visible, err := postVisibleTo(ctx, tx, postID, userID)
+		if err != nil {
+			return err
+		}
+		if !visible {
+			continue
+		}
+		n := &Notification{
+			UserID:    userID,
+			ActorID:   authorID,
+			Type:      NotificationMention,
+			PostID:    &postID,
+			CommentID: commentID,
+		}
+		if err := createNotification(ctx, tx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPostMentions fills in the mentions of each post's content.
+func loadPostMentions(ctx context.Context, q queryer, posts []*Post) error {
+	byID := make(map[int64]*Post, len(posts))
+	ids := make([]int64, len(posts))
+	for i, p := range posts {
+		p.Mentions = []Mention{}
+		byID[p.ID] = p
+		ids[i] = p.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `SELECT m.post_id, m.user_id, u.username, m.start_offset, m.end_offset
+	FROM mentions m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.post_id = ANY($1) AND m.comment_id IS NULL
+	ORDER BY m.post_id, m.start_offset`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID int64
+		var m Mention
+		if err := rows.Scan(&postID, &m.UserID, &m.Username, &m.Start, &m.End); err != nil {
+			return err
+		}
+		p := byID[postID]
+		p.Mentions = append(p.Mentions, m)
+	}
+	return rows.Err()
+}
+
+// loadCommentMentions fills in the mentions of each comment's content.
+func loadCommentMentions(ctx context.Context, q queryer, comments []*Comment) error {
+	byID := make(map[int64]*Comment, len(comments))
+	ids := make([]int64, len(comments))
+	for i, c := range comments {
+		c.Mentions = []Mention{}
+		byID[c.ID] = c
+		ids[i] = c.ID
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `SELECT m.comment_id, m.user_id, u.username, m.start_offset, m.end_offset
+	FROM mentions m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.comment_id = ANY($1)
+	ORDER BY m.comment_id, m.start_offset`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var commentID int64
+		var m Mention
+		if err := rows.Scan(&commentID, &m.UserID, &m.Username, &m.Start, &m.End); err != nil {
+			return err
+		}
+		c := byID[commentID]
+		c.Mentions = append(c.Mentions, m)
+	}
+	return rows.Err()
+}
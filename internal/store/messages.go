@@ -0,0 +1,332 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+// Conversation is a one-to-one direct message thread, seen from one of its
+// two members.
+type Conversation struct {
+	ID          int64             `json:"id"`
+	Participant NotificationActor `json:"participant"`
+	LastMessage *Message          `json:"last_message"`
+	UnreadCount int64             `json:"unread_count"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// Message is a direct message. Delivered and Read report whether the
+// recipient has received and read it.
+type Message struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderID       int64     `json:"sender_id"`
+	RecipientID    int64     `json:"recipient_id"`
+	Content        string    `json:"content"`
+	Delivered      bool      `json:"delivered"`
+	Read           bool      `json:"read"`
+	CreatedAt      time.Time `json:"created_at"`
+	// TxID is the transaction that sent the message, for syncing.
+	TxID int64 `json:"-"`
+}
+
+// Receipt reports how far UserID has received and read a conversation.
+// Every message up to DeliveredUpTo has been delivered to them and every
+// message up to ReadUpTo read. PeerID is the other member, the one the
+// receipt is for.
+type Receipt struct {
+	ConversationID int64 `json:"conversation_id"`
+	UserID         int64 `json:"user_id"`
+	DeliveredUpTo  int64 `json:"delivered_up_to"`
+	ReadUpTo       int64 `json:"read_up_to"`
+	PeerID         int64 `json:"-"`
+	Seq            int64 `json:"-"`
+	TxID           int64 `json:"-"`
+}
+
+type MessageStore struct {
+	db *sql.DB
+}
+
+// messageColumns selects messages m with their recipient's membership r, so
+// delivery and read state can be derived from the recipient's watermarks.
+const messageColumns = `SELECT m.id, m.conversation_id, m.sender_id, r.user_id, m.content,
+	m.id <= r.last_delivered_id, m.id <= r.last_read_id, m.created_at, m.txid
+FROM messages m
+JOIN conversation_members r ON r.conversation_id = m.conversation_id AND r.user_id <> m.sender_id`
+
+func scanMessage(row interface{ Scan(...any) error }, m *Message) error {
+	return row.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.RecipientID, &m.Content, &m.Delivered, &m.Read, &m.CreatedAt, &m.TxID)
+}
+
+// Send stores a message from msg.SenderID to msg.RecipientID, starting their
+// conversation if this is the first message. It returns ErrBlocked when
+// either user blocked the other, ErrNotFound when the recipient does not
+// exist or is deactivated or suspended, and ErrPrivateAccount when the
+// recipient is private and the two do not follow each other in either
+// direction.
+func (s *MessageStore) Send(ctx context.Context, msg *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT u.is_private,
+			EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = $1 AND b.blocked_id = $2) OR (b.blocker_id = $2 AND b.blocked_id = $1)),
+			EXISTS (SELECT 1 FROM followers f WHERE (f.user_id = $2 AND f.follower_id = $1) OR (f.user_id = $1 AND f.follower_id = $2))
+		FROM users u WHERE u.id = $2 AND u.deactivated_at IS NULL AND ` + notSuspended("u.id")
+		var isPrivate, blocked, connected bool
+		err := tx.QueryRowContext(ctx, query, msg.SenderID, msg.RecipientID).Scan(&isPrivate, &blocked, &connected)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		if blocked {
+			return ErrBlocked
+		}
+		if isPrivate && !connected {
+			return ErrPrivateAccount
+		}
+
+		// Updating the row on conflict locks the conversation, so messages
+		// in it are numbered in the order they are committed.
+		query = `INSERT INTO conversations (user_low_id, user_high_id) VALUES (LEAST($1::bigint, $2::bigint), GREATEST($1::bigint, $2::bigint))
+		ON CONFLICT (user_low_id, user_high_id) DO UPDATE SET user_low_id = EXCLUDED.user_low_id
+		RETURNING id`
+		if err := tx.QueryRowContext(ctx, query, msg.SenderID, msg.RecipientID).Scan(&msg.ConversationID); err != nil {
+			return err
+		}
+		query = `INSERT INTO conversation_members (conversation_id, user_id) VALUES ($1, $2), ($1, $3) ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, msg.ConversationID, msg.SenderID, msg.RecipientID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO messages (conversation_id, sender_id, content) VALUES ($1, $2, $3) RETURNING id, created_at`
+		if err := tx.QueryRowContext(ctx, query, msg.ConversationID, msg.SenderID, msg.Content).Scan(&msg.ID, &msg.CreatedAt); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE conversations SET last_message_id = $1 WHERE id = $2`, msg.ID, msg.ConversationID); err != nil {
+			return err
+		}
+		// Senders have seen their own messages.
+		query = `UPDATE conversation_members SET last_delivered_id = $1, last_read_id = $1, receipt_seq = nextval('conversation_receipt_seq'), receipt_txid = txid_current()
+		WHERE conversation_id = $2 AND user_id = $3`
+		_, err = tx.ExecContext(ctx, query, msg.ID, msg.ConversationID, msg.SenderID)
+		return err
+	})
+}
+
+// GetConversations returns userID's conversations, most recently active
+// first. Pages continue from the last message ID of the last conversation of
+// the previous page, passed as mq.Before.
+func (s *MessageStore) GetConversations(ctx context.Context, userID int64, mq MessageQuery) ([]Conversation, error) {
+	query := `SELECT c.id, c.created_at, u.id, u.username,
+		(SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND m.id > me.last_read_id AND m.sender_id <> me.user_id),
+		lm.id, lm.sender_id, lm.content, lm.id <= other.last_delivered_id, lm.id <= other.last_read_id, lm.created_at
+	FROM conversation_members me
+	JOIN conversations c ON c.id = me.conversation_id
+	JOIN conversation_members other ON other.conversation_id = c.id AND other.user_id <> me.user_id
+	JOIN users u ON u.id = other.user_id
+	JOIN messages lm ON lm.id = c.last_message_id
+	WHERE me.user_id = $1 AND ($2 = 0 OR c.last_message_id < $2)
+	ORDER BY c.last_message_id DESC
+	LIMIT $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, mq.Before, mq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var c Conversation
+		lm := &Message{}
+		err := rows.Scan(&c.ID, &c.CreatedAt, &c.Participant.ID, &c.Participant.Username, &c.UnreadCount,
+			&lm.ID, &lm.SenderID, &lm.Content, &lm.Delivered, &lm.Read, &lm.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		lm.ConversationID = c.ID
+		lm.RecipientID = userID
+		if lm.SenderID == userID {
+			lm.RecipientID = c.Participant.ID
+		}
+		c.LastMessage = lm
+		conversations = append(conversations, c)
+	}
+	return conversations, rows.Err()
+}
+
+// GetMessages returns a page of the messages in a conversation, newest
+// first. It returns ErrNotFound unless userID is a member.
+func (s *MessageStore) GetMessages(ctx context.Context, conversationID, userID int64, mq MessageQuery) ([]Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var member bool
+	query := `SELECT EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = $1 AND user_id = $2)`
+	if err := s.db.QueryRowContext(ctx, query, conversationID, userID).Scan(&member); err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, ErrNotFound
+	}
+
+	query = messageColumns + `
+	WHERE m.conversation_id = $1 AND ($2 = 0 OR m.id < $2)
+	ORDER BY m.id DESC
+	LIMIT $3`
+	rows, err := s.db.QueryContext(ctx, query, conversationID, mq.Before, mq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetSince returns up to limit messages in any of userID's conversations
+// after pos, oldest first.
+func (s *MessageStore) GetSince(ctx context.Context, userID int64, pos Position, limit int) ([]Message, error) {
+	query := messageColumns + `
+	JOIN conversation_members me ON me.conversation_id = m.conversation_id AND me.user_id = $1
+	WHERE ` + committedAfter("m.txid", "m.id", "$2", "$3") + `
+	ORDER BY ` + byCommit("m.txid", "m.id") + `
+	LIMIT $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, pos.TxID, pos.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+func scanMessages(rows *sql.Rows) ([]Message, error) {
+	defer rows.Close()
+	messages := []Message{}
+	for rows.Next() {
+		var m Message
+		if err := scanMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// SyncCursors returns where a newly connected client of userID should start
+// reading messages and receipts from: just before their oldest undelivered
+// message, or from what is committed now when everything has been
+// delivered, and from what is committed now for receipts.
+func (s *MessageStore) SyncCursors(ctx context.Context, userID int64) (messages, receipts Position, err error) {
+	query := `SELECT txid_snapshot_xmin(txid_current_snapshot()) - 1, first.txid, first.id
+	FROM (SELECT 1) one
+	LEFT JOIN LATERAL (
+		SELECT m.txid, m.id
+		FROM conversation_members me
+		JOIN messages m ON m.conversation_id = me.conversation_id AND m.id > me.last_delivered_id
+		WHERE me.user_id = $1
+		ORDER BY m.txid, m.id
+		LIMIT 1
+	) first ON true`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	start := Position{ID: math.MaxInt64}
+	var firstTxID, firstID sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&start.TxID, &firstTxID, &firstID); err != nil {
+		return Position{}, Position{}, err
+	}
+	// An undelivered message committed ahead of a transaction still
+	// running must not move the cursor past that transaction's messages.
+	messages = start
+	if firstID.Valid && firstTxID.Int64 <= start.TxID {
+		messages = Position{TxID: firstTxID.Int64, ID: firstID.Int64 - 1}
+	}
+	return messages, start, nil
+}
+
+// GetReceiptsSince returns up to limit receipts from the people userID talks
+// to that changed after pos, oldest first. A receipt's position is its
+// TxID and Seq.
+func (s *MessageStore) GetReceiptsSince(ctx context.Context, userID int64, pos Position, limit int) ([]Receipt, error) {
+	query := `SELECT other.conversation_id, other.user_id, other.last_delivered_id, other.last_read_id, other.receipt_seq, other.receipt_txid
+	FROM conversation_members me
+	JOIN conversation_members other ON other.conversation_id = me.conversation_id AND other.user_id <> me.user_id
+	WHERE me.user_id = $1 AND ` + committedAfter("other.receipt_txid", "other.receipt_seq", "$2", "$3") + `
+	ORDER BY ` + byCommit("other.receipt_txid", "other.receipt_seq") + `
+	LIMIT $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, pos.TxID, pos.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []Receipt{}
+	for rows.Next() {
+		r := Receipt{PeerID: userID}
+		if err := rows.Scan(&r.ConversationID, &r.UserID, &r.DeliveredUpTo, &r.ReadUpTo, &r.Seq, &r.TxID); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, rows.Err()
+}
+
+// MarkDelivered records that userID has received every message in a
+// conversation up to upTo. It returns the new receipt, or nil when nothing
+// changed.
+func (s *MessageStore) MarkDelivered(ctx context.Context, conversationID, userID, upTo int64) (*Receipt, error) {
+	query := `UPDATE conversation_members cm
+	SET last_delivered_id = LEAST($3, c.last_message_id), receipt_seq = nextval('conversation_receipt_seq'), receipt_txid = txid_current()
+	FROM conversations c
+	WHERE c.id = cm.conversation_id AND cm.conversation_id = $1 AND cm.user_id = $2 AND cm.last_delivered_id < LEAST($3, c.last_message_id)
+	RETURNING cm.last_delivered_id, cm.last_read_id, cm.receipt_seq, cm.receipt_txid,
+		(SELECT user_id FROM conversation_members WHERE conversation_id = $1 AND user_id <> $2)`
+	return s.advanceReceipt(ctx, query, conversationID, userID, upTo)
+}
+
+// MarkRead records that userID has read, and so received, every message in
+// a conversation up to upTo. It returns the new receipt, or nil when nothing
+// changed, and ErrNotFound unless userID is a member.
+func (s *MessageStore) MarkRead(ctx context.Context, conversationID, userID, upTo int64) (*Receipt, error) {
+	query := `UPDATE conversation_members cm
+	SET last_read_id = LEAST($3, c.last_message_id),
+		last_delivered_id = GREATEST(cm.last_delivered_id, LEAST($3, c.last_message_id)),
+		receipt_seq = nextval('conversation_receipt_seq'), receipt_txid = txid_current()
+	FROM conversations c
+	WHERE c.id = cm.conversation_id AND cm.conversation_id = $1 AND cm.user_id = $2 AND cm.last_read_id < LEAST($3, c.last_message_id)
+	RETURNING cm.last_delivered_id, cm.last_read_id, cm.receipt_seq, cm.receipt_txid,
+		(SELECT user_id FROM conversation_members WHERE conversation_id = $1 AND user_id <> $2)`
+	return s.advanceReceipt(ctx, query, conversationID, userID, upTo)
+}
+
+func (s *MessageStore) advanceReceipt(ctx context.Context, query string, conversationID, userID, upTo int64) (*Receipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	r := &Receipt{ConversationID: conversationID, UserID: userID}
+	err := s.db.QueryRowContext(ctx, query, conversationID, userID, upTo).Scan(&r.DeliveredUpTo, &r.ReadUpTo, &r.Seq, &r.TxID, &r.PeerID)
+	if err == nil {
+		return r, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var member bool
+	query = `SELECT EXISTS (SELECT 1 FROM conversation_members WHERE conversation_id = $1 AND user_id = $2)`
+	if err := s.db.QueryRowContext(ctx, query, conversationID, userID).Scan(&member); err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, ErrNotFound
+	}
+	return nil, nil
+}
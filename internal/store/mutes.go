@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Mute struct {
+	MuterID   int64     `json:"muter_id"`
+	MutedID   int64     `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MuteStore struct {
+	db *sql.DB
+}
+
+func (s *MuteStore) Mute(ctx context.Context, muterID, mutedID int64) error {
+	query := `INSERT INTO mutes (muter_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (s *MuteStore) Unmute(ctx context.Context, muterID, mutedID int64) error {
+	query := `DELETE FROM mutes WHERE muter_id=$1 AND muted_id=$2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationMention        = "mention"
+	NotificationComment        = "comment"
+	NotificationFollow         = "follow"
+	NotificationFollowRequest  = "follow_request"
+	NotificationFollowAccepted = "follow_accepted"
+	NotificationRepost         = "repost"
+	NotificationQuote          = "quote"
+	// NotificationWarning tells a user a moderator acted on their content.
+	// Its actor is the user themselves, so moderators stay anonymous.
+	NotificationWarning = "moderation_warning"
+)
+
+// maxGroupActors is how many of the most recent actors a notification group
+// lists by name; the rest are only counted.
+const maxGroupActors = 3
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	ActorID   int64      `json:"actor_id"`
+	Type      string     `json:"type"`
+	PostID    *int64     `json:"post_id"`
+	CommentID *int64     `json:"comment_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	// TxID is the transaction that created the notification, for
+	// streaming. It is only set by GetSince.
+	TxID int64 `json:"-"`
+}
+
+// NotificationGroup folds notifications of the same kind about the same
+// target on the same day into one entry, e.g. several follows or several
+// comments on one post. Mentions, quotes and follow requests are never
+// grouped.
+type NotificationGroup struct {
+	Type            string              `json:"type"`
+	PostID          *int64              `json:"post_id"`
+	CommentID       *int64              `json:"comment_id"`
+	Actors          []NotificationActor `json:"actors"`
+	ActorCount      int                 `json:"actor_count"`
+	NotificationIDs []int64             `json:"notification_ids"`
+	Read            bool                `json:"read"`
+	LatestID        int64               `json:"latest_id"`
+	CreatedAt       time.Time           `json:"created_at"`
+	Summary         string              `json:"summary"`
+}
+
+type NotificationActor struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// GetGroups returns a page of userID's notifications, grouped and newest
+// first. Pages continue from the LatestID of the last group of the previous
+// page, passed as nq.Before.
+func (s *NotificationStore) GetGroups(ctx context.Context, userID int64, nq NotificationQuery) ([]NotificationGroup, error) {
+	query := `SELECT g.type, g.post_id, MAX(g.id) AS latest_id, MAX(g.created_at),
+		(array_agg(g.comment_id ORDER BY g.id DESC))[1],
+		array_agg(g.id ORDER BY g.id DESC),
+		array_agg(g.actor_id ORDER BY g.id DESC),
+		array_agg(u.username ORDER BY g.id DESC),
+		bool_and(g.read_at IS NOT NULL)
+	FROM (
+		SELECT n.*,
+			CASE WHEN n.type IN ('follow', 'follow_accepted', 'comment', 'repost')
+				THEN n.type || ':' || COALESCE(n.post_id, 0) || ':' || (n.read_at IS NULL) || ':' || to_char(n.created_at, 'YYYY-MM-DD')
+				ELSE 'single:' || n.id
+			END AS group_key
+		FROM notifications n
+		WHERE n.user_id = $1 AND
+			(cardinality($2::text[]) = 0 OR n.type = ANY($2)) AND
+			(NOT $3 OR n.read_at IS NULL) AND
+			NOT EXISTS (SELECT 1 FROM blocks b WHERE b.blocker_id = n.user_id AND b.blocked_id = n.actor_id) AND
+			` + onLivePost("n.post_id") + `
+	) g
+	JOIN users u ON u.id = g.actor_id
+	GROUP BY g.group_key, g.type, g.post_id
+	HAVING $4 = 0 OR MAX(g.id) < $4
+	ORDER BY latest_id DESC
+	LIMIT $5`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(nq.Types), nq.Unread, nq.Before, nq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []NotificationGroup{}
+	for rows.Next() {
+		var g NotificationGroup
+		var actorIDs []int64
+		var usernames []string
+		err := rows.Scan(&g.Type, &g.PostID, &g.LatestID, &g.CreatedAt, &g.CommentID,
+			pq.Array(&g.NotificationIDs), pq.Array(&actorIDs), pq.Array(&usernames), &g.Read)
+		if err != nil {
+			return nil, err
+		}
+
+		g.Actors = []NotificationActor{}
+		seen := map[int64]bool{}
+		for i, id := range actorIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if len(g.Actors) < maxGroupActors {
+				g.Actors = append(g.Actors, NotificationActor{ID: id, Username: usernames[i]})
+			}
+		}
+		g.ActorCount = len(seen)
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// MarkRead marks userID's notifications with the given IDs, and every
+// notification up to and including upTo when it is not zero, as read. It
+// returns how many were newly marked.
+func (s *NotificationStore) MarkRead(ctx context.Context, userID int64, ids []int64, upTo int64) (int64, error) {
+	query := `UPDATE notifications SET read_at = NOW()
+	WHERE user_id = $1 AND read_at IS NULL AND (id = ANY($2) OR id <= $3)`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := s.db.ExecContext(ctx, query, userID, pq.Array(ids), upTo)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *NotificationStore) UnreadCount(ctx context.Context, userID int64) (int64, error) {
+	query := `SELECT COUNT(*) FROM notifications n
+	WHERE n.user_id = $1 AND n.read_at IS NULL AND
+		NOT EXISTS (SELECT 1 FROM blocks b WHERE b.blocker_id = n.user_id AND b.blocked_id = n.actor_id) AND
+		` + onLivePost("n.post_id")
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var count int64
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// createNotification records n as part of the write that caused it. Nothing
+// is recorded when the recipient has blocked the actor.
+func createNotification(ctx context.Context, tx *sql.Tx, n *Notification) error {
+	query := `INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id)
+	SELECT $1, $2, $3, $4, $5
+	WHERE NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2)
+	RETURNING id, created_at`
+	err := tx.QueryRowContext(ctx, query, n.UserID, n.ActorID, n.Type, n.PostID, n.CommentID).Scan(&n.ID, &n.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// GetSince returns up to limit of userID's notifications after pos, oldest
+// first, leaving out those from users they have blocked and those about
+// deleted posts.
+func (s *NotificationStore) GetSince(ctx context.Context, userID int64, pos Position, limit int) ([]Notification, error) {
+	query := `SELECT n.id, n.user_id, n.actor_id, n.type, n.post_id, n.comment_id, n.read_at, n.created_at, n.txid
+	FROM notifications n
+	WHERE n.user_id = $1 AND ` + committedAfter("n.txid", "n.id", "$2", "$3") + ` AND
+		NOT EXISTS (SELECT 1 FROM blocks b WHERE b.blocker_id = n.user_id AND b.blocked_id = n.actor_id) AND
+		` + onLivePost("n.post_id") + `
+	ORDER BY ` + byCommit("n.txid", "n.id") + `
+	LIMIT $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID, pos.TxID, pos.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.ActorID, &n.Type, &n.PostID, &n.CommentID, &n.ReadAt, &n.CreatedAt, &n.TxID); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
@@ -65,3 +65,457 @@ func parseTime(s string) string {
 	}
 	return t.Format(time.DateTime)
 }
+
+type NotificationQuery struct {
+	Limit  int      `json:"limit" validate:"gte=1,lte=50"`
+	Before int64    `json:"before" validate:"gte=0"`
+	Types  []string `json:"types" validate:"max=5,dive,oneof=mention comment follow follow_request follow_accepted repost quote moderation_warning"`
+	Unread bool     `json:"unread"`
+}
+
+func (nq NotificationQuery) Parse(r *http.Request) (NotificationQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return nq, err
+		}
+		nq.Limit = l
+	}
+	before := qs.Get("before")
+	if before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return nq, err
+		}
+		nq.Before = b
+	}
+	types := qs.Get("type")
+	if types != "" {
+		nq.Types = strings.Split(types, ",")
+	}
+	unread := qs.Get("unread")
+	if unread != "" {
+		u, err := strconv.ParseBool(unread)
+		if err != nil {
+			return nq, err
+		}
+		nq.Unread = u
+	}
+	return nq, nil
+}
+
+type MessageQuery struct {
+	Limit  int   `json:"limit" validate:"gte=1,lte=50"`
+	Before int64 `json:"before" validate:"gte=0"`
+}
+
+func (mq MessageQuery) Parse(r *http.Request) (MessageQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return mq, err
+		}
+		mq.Limit = l
+	}
+	before := qs.Get("before")
+	if before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return mq, err
+		}
+		mq.Before = b
+	}
+	return mq, nil
+}
+
+type BookmarkQuery struct {
+	Limit        int   `json:"limit" validate:"gte=1,lte=50"`
+	Before       int64 `json:"before" validate:"gte=0"`
+	CollectionID int64 `json:"collection_id" validate:"gte=0"`
+}
+
+func (bq BookmarkQuery) Parse(r *http.Request) (BookmarkQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return bq, err
+		}
+		bq.Limit = l
+	}
+	before := qs.Get("before")
+	if before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return bq, err
+		}
+		bq.Before = b
+	}
+	collection := qs.Get("collection")
+	if collection != "" {
+		c, err := strconv.ParseInt(collection, 10, 64)
+		if err != nil {
+			return bq, err
+		}
+		bq.CollectionID = c
+	}
+	return bq, nil
+}
+
+type TrashQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=50"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (tq TrashQuery) Parse(r *http.Request) (TrashQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return tq, err
+		}
+		tq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return tq, err
+		}
+		tq.Offset = o
+	}
+	return tq, nil
+}
+
+type DraftQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Status string `json:"status" validate:"omitempty,oneof=draft scheduled"`
+}
+
+func (dq DraftQuery) Parse(r *http.Request) (DraftQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return dq, err
+		}
+		dq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return dq, err
+		}
+		dq.Offset = o
+	}
+	status := qs.Get("status")
+	if status != "" {
+		dq.Status = status
+	}
+	return dq, nil
+}
+
+type WebhookDeliveryQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Status string `json:"status" validate:"omitempty,oneof=pending succeeded failed"`
+}
+
+func (wq WebhookDeliveryQuery) Parse(r *http.Request) (WebhookDeliveryQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return wq, err
+		}
+		wq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return wq, err
+		}
+		wq.Offset = o
+	}
+	status := qs.Get("status")
+	if status != "" {
+		wq.Status = status
+	}
+	return wq, nil
+}
+
+type ReportQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	Status     string `json:"status" validate:"omitempty,oneof=open resolved dismissed"`
+	TargetType string `json:"target_type" validate:"omitempty,oneof=post comment user"`
+	Reason     string `json:"reason" validate:"omitempty,max=32"`
+	// AssigneeID limits the queue to reports assigned to one moderator and
+	// Unassigned to reports nobody has picked up.
+	AssigneeID int64 `json:"assignee_id" validate:"gte=0"`
+	Unassigned bool  `json:"unassigned"`
+}
+
+// Parse reads the query string. The assignee parameter takes a user ID or
+// "unassigned"; resolving "me" is left to the caller, which knows the viewer.
+func (rq ReportQuery) Parse(r *http.Request) (ReportQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return rq, err
+		}
+		rq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return rq, err
+		}
+		rq.Offset = o
+	}
+	if status := qs.Get("status"); status != "" {
+		rq.Status = status
+	}
+	if targetType := qs.Get("target_type"); targetType != "" {
+		rq.TargetType = targetType
+	}
+	if reason := qs.Get("reason"); reason != "" {
+		rq.Reason = reason
+	}
+	switch assignee := qs.Get("assignee"); assignee {
+	case "", "me":
+	case "unassigned":
+		rq.Unassigned = true
+	default:
+		a, err := strconv.ParseInt(assignee, 10, 64)
+		if err != nil {
+			return rq, err
+		}
+		rq.AssigneeID = a
+	}
+	return rq, nil
+}
+
+type SuspensionQuery struct {
+	Limit  int   `json:"limit" validate:"gte=1,lte=100"`
+	Offset int   `json:"offset" validate:"gte=0"`
+	UserID int64 `json:"user_id" validate:"gte=0"`
+	// Active leaves out suspensions that expired or were lifted.
+	Active bool `json:"active"`
+}
+
+func (sq SuspensionQuery) Parse(r *http.Request) (SuspensionQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+		sq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return sq, err
+		}
+		sq.Offset = o
+	}
+	userID := qs.Get("user_id")
+	if userID != "" {
+		u, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return sq, err
+		}
+		sq.UserID = u
+	}
+	active := qs.Get("active")
+	if active != "" {
+		a, err := strconv.ParseBool(active)
+		if err != nil {
+			return sq, err
+		}
+		sq.Active = a
+	}
+	return sq, nil
+}
+
+type AuditQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=100"`
+	Before     int64  `json:"before" validate:"gte=0"`
+	ActorID    int64  `json:"actor_id" validate:"gte=0"`
+	TargetType string `json:"target_type" validate:"omitempty,max=32"`
+	TargetID   int64  `json:"target_id" validate:"gte=0"`
+	Action     string `json:"action" validate:"omitempty,max=64"`
+	// Since and Until bound when the entries were recorded, Until
+	// exclusively.
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+}
+
+func (aq AuditQuery) Parse(r *http.Request) (AuditQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return aq, err
+		}
+		aq.Limit = l
+	}
+	before := qs.Get("before")
+	if before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return aq, err
+		}
+		aq.Before = b
+	}
+	actorID := qs.Get("actor_id")
+	if actorID != "" {
+		a, err := strconv.ParseInt(actorID, 10, 64)
+		if err != nil {
+			return aq, err
+		}
+		aq.ActorID = a
+	}
+	targetType := qs.Get("target_type")
+	if targetType != "" {
+		aq.TargetType = targetType
+	}
+	targetID := qs.Get("target_id")
+	if targetID != "" {
+		t, err := strconv.ParseInt(targetID, 10, 64)
+		if err != nil {
+			return aq, err
+		}
+		aq.TargetID = t
+	}
+	action := qs.Get("action")
+	if action != "" {
+		aq.Action = action
+	}
+	since := qs.Get("since")
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return aq, err
+		}
+		aq.Since = &t
+	}
+	until := qs.Get("until")
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return aq, err
+		}
+		aq.Until = &t
+	}
+	return aq, nil
+}
+
+type AdminUserQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=100"`
+	Offset int `json:"offset" validate:"gte=0"`
+	// Search matches part of a username or email address.
+	Search string `json:"q" validate:"max=100"`
+	Role   string `json:"role" validate:"omitempty,oneof=user moderator admin"`
+}
+
+func (uq AdminUserQuery) Parse(r *http.Request) (AdminUserQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return uq, err
+		}
+		uq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return uq, err
+		}
+		uq.Offset = o
+	}
+	search := qs.Get("q")
+	if search != "" {
+		uq.Search = search
+	}
+	role := qs.Get("role")
+	if role != "" {
+		uq.Role = role
+	}
+	return uq, nil
+}
+
+type AdminListQuery struct {
+	Limit  int `json:"limit" validate:"gte=1,lte=100"`
+	Offset int `json:"offset" validate:"gte=0"`
+}
+
+func (aq AdminListQuery) Parse(r *http.Request) (AdminListQuery, error) {
+	qs := r.URL.Query()
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return aq, err
+		}
+		aq.Limit = l
+	}
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return aq, err
+		}
+		aq.Offset = o
+	}
+	return aq, nil
+}
+
+// StatsQuery spans the days from From to To, inclusive, given as
+// YYYY-MM-DD dates in UTC.
+type StatsQuery struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to" validate:"gtefield=From"`
+}
+
+func (sq StatsQuery) Parse(r *http.Request) (StatsQuery, error) {
+	qs := r.URL.Query()
+	from := qs.Get("from")
+	if from != "" {
+		t, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return sq, err
+		}
+		sq.From = t
+	}
+	to := qs.Get("to")
+	if to != "" {
+		t, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return sq, err
+		}
+		sq.To = t
+	}
+	return sq, nil
+}
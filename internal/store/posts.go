@@ -4,106 +4,542 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	USERID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int64     `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID         int64     `json:"id"`
+	Content    string    `json:"content"`
+	Title      string    `json:"title"`
+	USERID     int64     `json:"user_id"`
+	Tags       []string  `json:"tags"`
+	CreatedAt  string    `json:"created_at"`
+	UpdatedAt  string    `json:"updated_at"`
+	Version    int64     `json:"version"`
+	Visibility string    `json:"visibility"`
+	Comments   []Comment `json:"comments"`
+	User       User      `json:"user"`
+	MediaIDs   []int64   `json:"-"`
+	Media      []Media   `json:"media"`
+	Mentions   []Mention `json:"mentions"`
+	Bookmarked bool      `json:"bookmarked"`
+	Edited     bool      `json:"edited"`
+	// RepostedPostID is set on reposts, which share another post without
+	// content of their own. QuotedPostID is set on quote posts, which share
+	// another post with commentary.
+	RepostedPostID *int64       `json:"reposted_post_id"`
+	QuotedPostID   *int64       `json:"quoted_post_id"`
+	RepostedPost   *PostSummary `json:"reposted_post"`
+	QuotedPost     *PostSummary `json:"quoted_post"`
+	// Status is draft or scheduled until the post is published, which for
+	// scheduled posts happens at PublishAt. Only the author sees unpublished
+	// posts.
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	// PublishedSeq orders published posts by when they were published, and
+	// PublishedTxID by when that was committed.
+	PublishedSeq  int64 `json:"-"`
+	PublishedTxID int64 `json:"-"`
+	// Hidden is set on posts a moderator has hidden, which only their
+	// author can still see.
+	Hidden bool `json:"hidden"`
+	// FlagDetails, when set, files an automated report on the post in the
+	// transaction that saves it, for content the filter flagged.
+	FlagDetails string `json:"-"`
+	// ContentFingerprint identifies the post's content when it was created,
+	// for the content filter to count repeats by.
+	ContentFingerprint []byte `json:"-"`
+}
+
+// PostSummary is a shared post embedded in a repost or quote post. It is
+// left out when the viewer may not see the original.
+type PostSummary struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
 }
 
 type PostWithMetadata struct {
 	Post
 	CommentCount int64 `json:"comment_count"`
+	RepostCount  int64 `json:"repost_count"`
+	QuoteCount   int64 `json:"quote_count"`
 }
 
 type PostStore struct {
 	db *sql.DB
 }
 
+// visibleTo returns a WHERE clause fragment matching the posts p, written by
+// users u, that the viewer bound to placeholder may see: their own posts, and
+// otherwise published posts whose visibility, author privacy and blocks
+// allow it. Deleted posts are never visible, hidden posts only to their
+// author, and a repost is only visible when the post it shares is and that
+// post's author is neither suspended nor deactivated.
+func visibleTo(placeholder string) string {
+	return `(` + visibleToAs("p", "u", placeholder) + ` AND
+		(p.reposted_post_id IS NULL OR EXISTS (
+			SELECT 1 FROM posts op JOIN users ou ON ou.id = op.user_id
+			WHERE op.id = p.reposted_post_id AND ` + visibleToAs("op", "ou", placeholder) + ` AND ` + byActiveAuthor("op", "ou") + `)))`
+}
+
+// byActiveAuthor returns a WHERE clause fragment matching posts aliased
+// post whose author, aliased author, is neither deactivated nor suspended.
+func byActiveAuthor(post, author string) string {
+	return author + `.deactivated_at IS NULL AND ` + notSuspended(post+".user_id")
+}
+
+// visibleToAs is visibleTo for posts aliased post written by users aliased
+// author, ignoring what reposts share.
+func visibleToAs(post, author, placeholder string) string {
+	return strings.NewReplacer("$viewer", placeholder, "$post", post, "$author", author).Replace(`($post.deleted_at IS NULL AND ($post.user_id = $viewer OR ($post.status = 'published' AND $post.hidden_at IS NULL AND
+		NOT EXISTS (SELECT 1 FROM blocks vb WHERE (vb.blocker_id = $viewer AND vb.blocked_id = $post.user_id) OR (vb.blocker_id = $post.user_id AND vb.blocked_id = $viewer)) AND
+		(NOT $author.is_private OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = $post.user_id AND vf.follower_id = $viewer)) AND
+		($post.visibility = 'public' OR
+			($post.visibility = 'followers' AND EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = $post.user_id AND vf.follower_id = $viewer)) OR
+			($post.visibility = 'mentioned' AND EXISTS (SELECT 1 FROM mentions vm WHERE vm.post_id = $post.id AND vm.comment_id IS NULL AND vm.user_id = $viewer)))
+	)))`)
+}
+
+// onLivePost returns a WHERE clause fragment matching rows whose post ID
+// column, which may be NULL, does not name a deleted post.
+func onLivePost(column string) string {
+	return `NOT EXISTS (SELECT 1 FROM posts dp WHERE dp.id = ` + column + ` AND dp.deleted_at IS NOT NULL)`
+}
+
 func (p *PostStore) Create(ctx context.Context, post *Post) error {
 	query :=
-		`INSERT INTO posts (content, title, user_id, tags)
-	VALUES($1,$2,$3,$4) RETURNING id, created_at, updated_at`
+		`INSERT INTO posts (content, title, user_id, tags, visibility, quoted_post_id, status, publish_at, content_fingerprint, published_seq, published_txid)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9, CASE WHEN $7 = 'published' THEN nextval('post_publish_seq') END, CASE WHEN $7 = 'published' THEN txid_current() END)
+	RETURNING id, created_at, updated_at, COALESCE(published_seq, 0)`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := p.db.QueryRowContext(ctx, query, post.Content, post.Title, post.USERID, pq.Array(post.Tags)).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
-
-	return err
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		if post.QuotedPostID != nil {
+			if err := resolveQuotedPost(ctx, tx, post); err != nil {
+				return err
+			}
+		}
+		err := tx.QueryRowContext(ctx, query, post.Content, post.Title, post.USERID, pq.Array(post.Tags), post.Visibility, post.QuotedPostID, post.Status, post.PublishAt, post.ContentFingerprint).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt, &post.PublishedSeq)
+		if err != nil {
+			return err
+		}
+		if err := attachPostMedia(ctx, tx, post); err != nil {
+			return err
+		}
+		post.Mentions, err = replaceMentions(ctx, tx, post.ID, nil, post.USERID, post.Content)
+		if err != nil {
+			return err
+		}
+		if post.QuotedPostID != nil {
+			if err := notifyQuotedAuthor(ctx, tx, post); err != nil {
+				return err
+			}
+		}
+		if err := flagContent(ctx, tx, ReportTargetPost, post.ID, post.USERID, post.FlagDetails); err != nil {
+			return err
+		}
+		if err := recordEvent(ctx, tx, EventPostCreated, postEvent(post)); err != nil {
+			return err
+		}
+		if post.Status == PostStatusPublished {
+			return recordEvent(ctx, tx, EventPostPublished, postEvent(post))
+		}
+		return nil
+	})
 }
 
-func (p *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
-	query := `SELECT id, user_id, title, content, tags, created_at, updated_at, version FROM posts WHERE id = $1`
-	post := &Post{}
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-	err := p.db.QueryRowContext(ctx, query, id).Scan(&post.ID, &post.USERID, &post.Title, &post.Content, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt, &post.Version)
+// resolveQuotedPost points post.QuotedPostID at the original when it names a
+// repost. It returns ErrInvalidQuote unless the post is published and the
+// author may see it.
+func resolveQuotedPost(ctx context.Context, tx *sql.Tx, post *Post) error {
+	err := tx.QueryRowContext(ctx, `SELECT COALESCE(reposted_post_id, id) FROM posts WHERE id = $1 AND status = 'published'`, *post.QuotedPostID).Scan(post.QuotedPostID)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrNotFound
+			return ErrInvalidQuote
 		default:
-			return nil, err
+			return err
 		}
 	}
-	return post, err
+	visible, err := postVisibleTo(ctx, tx, *post.QuotedPostID, post.USERID)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return ErrInvalidQuote
+	}
+	return nil
 }
 
-func (p *PostStore) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
+// notifyQuotedAuthor tells the author of a quoted post about the quote,
+// unless they wrote it, were already mentioned in it, or cannot see it.
+func notifyQuotedAuthor(ctx context.Context, tx *sql.Tx, post *Post) error {
+	var authorID int64
+	if err := tx.QueryRowContext(ctx, `SELECT user_id FROM posts WHERE id = $1`, *post.QuotedPostID).Scan(&authorID); err != nil {
+		return err
+	}
+	if authorID == post.USERID {
+		return nil
+	}
+	for _, m := range post.Mentions {
+		if m.UserID == authorID {
+			return nil
+		}
+	}
+	visible, err := postVisibleTo(ctx, tx, post.ID, authorID)
+	if err != nil || !visible {
+		return err
+	}
+	return createNotification(ctx, tx, &Notification{
+		UserID:  authorID,
+		ActorID: post.USERID,
+		Type:    NotificationQuote,
+		PostID:  &post.ID,
+	})
+}
 
+// Repost shares a post with userID's followers. Reposting a repost shares
+// the original. It returns ErrNotFound when userID may not see the post,
+// ErrNotShareable unless it is a published public post by a public account and
+// ErrConflict when userID already reposted it.
+func (p *PostStore) Repost(ctx context.Context, userID, postID int64) (*Post, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
+	repost := &Post{
+		USERID:     userID,
+		Tags:       []string{},
+		Visibility: VisibilityPublic,
+		Status:     PostStatusPublished,
+		Media:      []Media{},
+		Mentions:   []Mention{},
+	}
+	err := withTx(p.db, ctx, func(tx *sql.Tx) error {
+		var originalID, authorID int64
+		var shareable bool
+		query := `SELECT op.id, op.user_id, op.visibility = 'public' AND op.status = 'published' AND NOT u.is_private
+		FROM posts p
+		JOIN posts op ON op.id = COALESCE(p.reposted_post_id, p.id)
+		JOIN users u ON u.id = op.user_id
+		WHERE p.id = $1`
+		if err := tx.QueryRowContext(ctx, query, postID).Scan(&originalID, &authorID, &shareable); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		visible, err := postVisibleTo(ctx, tx, originalID, userID)
+		if err != nil {
+			return err
+		}
+		if !visible {
+			return ErrNotFound
+		}
+		if !shareable {
+			return ErrNotShareable
+		}
 
-	res, err := p.db.ExecContext(ctx, query, id)
+		query = `INSERT INTO posts (content, title, user_id, tags, visibility, reposted_post_id)
+		VALUES ('', '', $1, '{}', $2, $3) RETURNING id, created_at, updated_at, version, published_seq`
+		err = tx.QueryRowContext(ctx, query, userID, repost.Visibility, originalID).Scan(&repost.ID, &repost.CreatedAt, &repost.UpdatedAt, &repost.Version, &repost.PublishedSeq)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+		repost.RepostedPostID = &originalID
+		if err := recordEvent(ctx, tx, EventPostCreated, postEvent(repost)); err != nil {
+			return err
+		}
+		if authorID == userID {
+			return nil
+		}
+		return createNotification(ctx, tx, &Notification{
+			UserID:  authorID,
+			ActorID: userID,
+			Type:    NotificationRepost,
+			PostID:  &originalID,
+		})
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	rowsAffected, err := res.RowsAffected()
+	return repost, nil
+}
+
+// Unrepost removes userID's repost of a post, or of the post a repost
+// shares. It returns ErrNotFound when they have not reposted it.
+func (p *PostStore) Unrepost(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM posts
+	WHERE user_id = $1 AND deleted_at IS NULL AND reposted_post_id = (SELECT COALESCE(reposted_post_id, id) FROM posts WHERE id = $2)
+	RETURNING id, reposted_post_id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		repost := &Post{USERID: userID, Status: PostStatusPublished}
+		if err := tx.QueryRowContext(ctx, query, userID, postID).Scan(&repost.ID, &repost.RepostedPostID); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		return recordEvent(ctx, tx, EventPostDeleted, postEvent(repost))
+	})
+}
+
+// LoadEmbedded fills in the posts that reposts and quote posts share, as
+// far as viewerID may see them. Posts by suspended or deactivated users are
+// left out.
+func (p *PostStore) LoadEmbedded(ctx context.Context, viewerID int64, posts []*Post) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return loadEmbeddedPosts(ctx, p.db, viewerID, posts)
+}
+
+func loadEmbeddedPosts(ctx context.Context, q queryer, viewerID int64, posts []*Post) error {
+	ids := []int64{}
+	for _, post := range posts {
+		if post.RepostedPostID != nil {
+			ids = append(ids, *post.RepostedPostID)
+		}
+		if post.QuotedPostID != nil {
+			ids = append(ids, *post.QuotedPostID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `SELECT p.id, p.user_id, u.username, p.title, p.content, p.created_at
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = ANY($1) AND ` + byActiveAuthor("p", "u") + ` AND ` + visibleTo("$2")
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids), viewerID)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+	defer rows.Close()
+	byID := map[int64]*PostSummary{}
+	for rows.Next() {
+		var summary PostSummary
+		if err := rows.Scan(&summary.ID, &summary.UserID, &summary.Username, &summary.Title, &summary.Content, &summary.CreatedAt); err != nil {
+			return err
+		}
+		byID[summary.ID] = &summary
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	return err
+
+	for _, post := range posts {
+		if post.RepostedPostID != nil {
+			post.RepostedPost = byID[*post.RepostedPostID]
+		}
+		if post.QuotedPostID != nil {
+			post.QuotedPost = byID[*post.QuotedPostID]
+		}
+	}
+	return nil
 }
 
-func (p *PostStore) Update(ctx context.Context, post *Post) error {
-	query := `UPDATE posts SET title=$1, content=$2, updated_at=NOW(), version=version+1 WHERE id=$3 AND Version=$4 RETURNING version`
+func (p *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	query := `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.visibility, p.reposted_post_id, p.quoted_post_id, p.status, p.publish_at, p.hidden_at IS NOT NULL, u.id, u.username, u.is_private
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.id = $1 AND p.deleted_at IS NULL`
+	post := &Post{}
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := p.db.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version).Scan(&post.Version)
+	err := p.db.QueryRowContext(ctx, query, id).Scan(&post.ID, &post.USERID, &post.Title, &post.Content, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt, &post.Version, &post.Visibility, &post.RepostedPostID, &post.QuotedPostID, &post.Status, &post.PublishAt, &post.Hidden, &post.User.ID, &post.User.Username, &post.User.IsPrivate)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			return nil, ErrNotFound
 		default:
-			return err
+			return nil, err
 		}
 	}
-	return nil
+	post.Edited = post.Version > 0
+	if err := loadPostMentions(ctx, p.db, []*Post{post}); err != nil {
+		return nil, err
+	}
+	return post, nil
 }
 
+// Delete moves post to its author's trash, provided it is still at
+// post.Version. It returns ErrVersionConflict when the post has changed since
+// and ErrNotFound when it no longer exists or is already deleted.
+func (p *PostStore) Delete(ctx context.Context, post *Post) error {
+	query := `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND version = $2 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditPostDeleted, AuditTargetPost, "posts", post.ID, func() error {
+			res, err := tx.ExecContext(ctx, query, post.ID, post.Version)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return versionConflictOrNotFound(ctx, tx, post.ID)
+			}
+			return recordEvent(ctx, tx, EventPostDeleted, postEvent(post))
+		})
+	})
+}
+
+// versionConflictOrNotFound explains why a write conditioned on a post's
+// version matched nothing: ErrVersionConflict when the post still exists,
+// otherwise ErrNotFound.
+func versionConflictOrNotFound(ctx context.Context, q queryer, postID int64) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`, postID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+// Update saves post as a new version. Edits to a published post keep the
+// version they replace as a revision, while drafts and scheduled posts are
+// edited in place and published when post.Status asks for it. It returns
+// ErrVersionConflict when post.Version is no longer current, or the post was
+// published in the meantime, and ErrNotFound when the post no longer exists.
+func (p *PostStore) Update(ctx context.Context, post *Post) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditPostUpdated, AuditTargetPost, "posts", post.ID, func() error {
+			var status string
+			err := tx.QueryRowContext(ctx, `SELECT status FROM posts WHERE id=$1 AND version=$2 AND deleted_at IS NULL FOR UPDATE`, post.ID, post.Version).Scan(&status)
+			if err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return versionConflictOrNotFound(ctx, tx, post.ID)
+				default:
+					return err
+				}
+			}
+
+			publish := false
+			switch {
+			case status == PostStatusPublished && post.Status != PostStatusPublished:
+				return ErrVersionConflict
+			case status == PostStatusPublished:
+				revision := `INSERT INTO post_revisions (post_id, version, title, content, visibility, created_at)
+				SELECT id, version, title, content, visibility, updated_at FROM posts WHERE id=$1 AND version=$2
+				ON CONFLICT (post_id, version) DO NOTHING`
+				if _, err := tx.ExecContext(ctx, revision, post.ID, post.Version); err != nil {
+					return err
+				}
+			case post.Status == PostStatusPublished:
+				// Save the edit as a draft first so the mentions are in place
+				// when publishing notifies the mentioned users.
+				publish = true
+				post.Status = PostStatusDraft
+				post.PublishAt = nil
+			}
+
+			query := `UPDATE posts SET title=$1, content=$2, visibility=$3, status=$4, publish_at=$5, updated_at=NOW(), version=version+1
+			WHERE id=$6 RETURNING version, updated_at`
+			err = tx.QueryRowContext(ctx, query, post.Title, post.Content, post.Visibility, post.Status, post.PublishAt, post.ID).Scan(&post.Version, &post.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			post.Edited = true
+			post.Mentions, err = replaceMentions(ctx, tx, post.ID, nil, post.USERID, post.Content)
+			if err != nil {
+				return err
+			}
+			if err := flagContent(ctx, tx, ReportTargetPost, post.ID, post.USERID, post.FlagDetails); err != nil {
+				return err
+			}
+			if err := recordEvent(ctx, tx, EventPostUpdated, postEvent(post)); err != nil {
+				return err
+			}
+			if publish {
+				return publishPost(ctx, tx, post)
+			}
+			return nil
+		})
+	})
+}
+
+// IsVisibleTo reports whether viewerID may see the post with the given ID.
+func (p *PostStore) IsVisibleTo(ctx context.Context, postID, viewerID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return postVisibleTo(ctx, p.db, postID, viewerID)
+}
+
+func postVisibleTo(ctx context.Context, q queryer, postID, viewerID int64) (bool, error) {
+	query := `SELECT EXISTS (
+		SELECT 1 FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = $1 AND ` + visibleTo("$2") + `
+	)`
+	var visible bool
+	err := q.QueryRowContext(ctx, query, postID, viewerID).Scan(&visible)
+	return visible, err
+}
+
+// inFeedOf returns a WHERE clause fragment matching the posts p, written by
+// users u, that belong in the feed of the viewer bound to placeholder: their
+// own published posts and those of users they follow, minus posts by
+// suspended or deactivated users, posts by or reposted from muted users and
+// anything they may not see.
+func inFeedOf(placeholder string) string {
+	return strings.ReplaceAll(`p.status = 'published' AND `+byActiveAuthor("p", "u")+` AND
+		(p.user_id = $viewer OR EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $viewer)) AND
+		NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = $viewer AND m.muted_id = p.user_id) AND
+		NOT EXISTS (SELECT 1 FROM posts op JOIN mutes m ON m.muted_id = op.user_id WHERE op.id = p.reposted_post_id AND m.muter_id = $viewer) AND
+		`, "$viewer", placeholder) + visibleTo(placeholder)
+}
+
+// shareCounts selects how many times each post p was reposted and quoted.
+const shareCounts = `(SELECT COUNT(*) FROM posts rp WHERE rp.reposted_post_id = p.id AND rp.deleted_at IS NULL), (SELECT COUNT(*) FROM posts qp WHERE qp.quoted_post_id = p.id AND qp.status = 'published' AND qp.deleted_at IS NULL)`
+
 func (p *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
-	query := `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version,u.username, COUNT(c.id) as comments_count
+	query := `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.visibility, u.username, COUNT(c.id) as comments_count,
+		EXISTS (SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = $1),
+		p.reposted_post_id, p.quoted_post_id, ` + shareCounts + `, p.status, COALESCE(p.published_seq, 0), COALESCE(p.published_txid, 0)
 	FROM posts p
 	LEFT JOIN comments c ON p.id = c.post_id
 	LEFT JOIN users u ON p.user_id = u.id
-	JOIN followers f ON p.user_id = f.follower_id OR p.user_id = $1
 	WHERE 
-		f.user_id = $1 OR p.user_id = $1 AND
 		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-		(p.tags @> $5 OR $5 = '{}')
+		(p.tags @> $5 OR $5 = '{}') AND
+		` + inFeedOf("$1") + `
 	GROUP BY p.id, u.username
 	ORDER BY p.created_at ` + fq.Sort + `
 	LIMIT $2 OFFSET $3`
@@ -114,14 +550,57 @@ func (p *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 		return nil, err
 	}
 	defer rows.Close()
+	return p.scanFeed(ctx, rows, userID)
+}
+
+// GetFeedSince returns up to limit posts from userID's feed published after
+// pos, in the order they were published. A post's position is its
+// PublishedTxID and PublishedSeq.
+func (p *PostStore) GetFeedSince(ctx context.Context, userID int64, pos Position, limit int) ([]PostWithMetadata, error) {
+	query := `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.visibility, u.username,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id),
+		EXISTS (SELECT 1 FROM bookmarks bm WHERE bm.post_id = p.id AND bm.user_id = $1),
+		p.reposted_post_id, p.quoted_post_id, ` + shareCounts + `, p.status, COALESCE(p.published_seq, 0), COALESCE(p.published_txid, 0)
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	WHERE ` + committedAfter("p.published_txid", "p.published_seq", "$2", "$3") + ` AND ` + inFeedOf("$1") + `
+	ORDER BY ` + byCommit("p.published_txid", "p.published_seq") + `
+	LIMIT $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := p.db.QueryContext(ctx, query, userID, pos.TxID, pos.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return p.scanFeed(ctx, rows, userID)
+}
+
+func (p *PostStore) scanFeed(ctx context.Context, rows *sql.Rows, viewerID int64) ([]PostWithMetadata, error) {
 	feed := []PostWithMetadata{}
 	for rows.Next() {
 		post := PostWithMetadata{}
-		err := rows.Scan(&post.ID, &post.USERID, &post.Title, &post.Content, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt, &post.Version, &post.User.Username, &post.CommentCount)
+		err := rows.Scan(&post.ID, &post.USERID, &post.Title, &post.Content, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt, &post.Version, &post.Visibility, &post.User.Username, &post.CommentCount, &post.Bookmarked,
+			&post.RepostedPostID, &post.QuotedPostID, &post.RepostCount, &post.QuoteCount, &post.Status, &post.PublishedSeq, &post.PublishedTxID)
 		if err != nil {
 			return nil, err
 		}
+		post.Edited = post.Version > 0
 		feed = append(feed, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	if err := loadPostMentions(ctx, p.db, posts); err != nil {
+		return nil, err
+	}
+	if err := loadEmbeddedPosts(ctx, p.db, viewerID, posts); err != nil {
+		return nil, err
+	}
 	return feed, nil
 }
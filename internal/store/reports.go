@@ -0,0 +1,443 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSelfReport    = errors.New("you cannot report yourself or your own content")
+	ErrReportClosed  = errors.New("report is already closed")
+	ErrNotModerator  = errors.New("assignee is not a moderator")
+	ErrInvalidAction = errors.New("users cannot be hidden")
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+
+	// ReportReasonAutomated is the reason given on reports filed by the
+	// content filter.
+	ReportReasonAutomated = "automated"
+
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+
+	ModerationDismiss = "dismiss"
+	ModerationHide    = "hide"
+	ModerationWarn    = "warn"
+	ModerationSuspend = "suspend"
+)
+
+type Report struct {
+	ID int64 `json:"id"`
+	// ReporterID is nil for content flagged by the content filter.
+	ReporterID *int64 `json:"reporter_id"`
+	TargetType string `json:"target_type"`
+	TargetID   int64  `json:"target_id"`
+	// SubjectID is the author of the reported post or comment, or the
+	// reported user.
+	SubjectID  int64      `json:"subject_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	AssigneeID *int64     `json:"assignee_id"`
+	ResolvedBy *int64     `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// TargetReports is how many reports are open on the same target.
+	TargetReports int64 `json:"target_reports"`
+	// TargetHidden is set when the reported post or comment is hidden.
+	TargetHidden bool               `json:"target_hidden"`
+	Actions      []ModerationAction `json:"actions,omitempty"`
+}
+
+// ModerationAction records a decision on reported content. ModeratorID is
+// nil for content hidden automatically.
+type ModerationAction struct {
+	ID          int64     `json:"id"`
+	ReportID    *int64    `json:"report_id"`
+	ModeratorID *int64    `json:"moderator_id"`
+	Action      string    `json:"action"`
+	TargetType  string    `json:"target_type"`
+	TargetID    int64     `json:"target_id"`
+	SubjectID   int64     `json:"subject_id"`
+	Note        string    `json:"note"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ReportStore struct {
+	db *sql.DB
+}
+
+const reportColumns = `r.id, r.reporter_id, r.target_type, r.target_id, r.subject_id, r.reason, r.details, r.status, r.assignee_id, r.resolved_by, r.resolved_at, r.created_at, r.updated_at,
+	(SELECT COUNT(*) FROM reports tr WHERE tr.target_type = r.target_type AND tr.target_id = r.target_id AND tr.status = 'open'),
+	CASE r.target_type
+		WHEN 'post' THEN EXISTS (SELECT 1 FROM posts hp WHERE hp.id = r.target_id AND hp.hidden_at IS NOT NULL)
+		WHEN 'comment' THEN EXISTS (SELECT 1 FROM comments hc WHERE hc.id = r.target_id AND hc.hidden_at IS NOT NULL)
+		ELSE false
+	END`
+
+func scanReport(row interface{ Scan(...any) error }, r *Report) error {
+	return row.Scan(&r.ID, &r.ReporterID, &r.TargetType, &r.TargetID, &r.SubjectID, &r.Reason, &r.Details, &r.Status, &r.AssigneeID, &r.ResolvedBy, &r.ResolvedAt, &r.CreatedAt, &r.UpdatedAt,
+		&r.TargetReports, &r.TargetHidden)
+}
+
+// Create files report against content *report.ReporterID may see. Once
+// hideThreshold reports are open on a post or comment it is hidden until a
+// moderator dismisses them; a threshold of zero never hides anything. It
+// returns ErrNotFound when there is no such target, ErrSelfReport when it is
+// the reporter's own and ErrConflict when they already have an open report
+// on it.
+func (s *ReportStore) Create(ctx context.Context, report *Report, hideThreshold int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		subjectID, err := reportSubject(ctx, tx, report.TargetType, report.TargetID, *report.ReporterID)
+		if err != nil {
+			return err
+		}
+		if subjectID == *report.ReporterID {
+			return ErrSelfReport
+		}
+		report.SubjectID = subjectID
+
+		query := `INSERT INTO reports (reporter_id, target_type, target_id, subject_id, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at, updated_at`
+		err = tx.QueryRowContext(ctx, query, report.ReporterID, report.TargetType, report.TargetID, report.SubjectID, report.Reason, report.Details).Scan(&report.ID, &report.Status, &report.CreatedAt, &report.UpdatedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+
+		if hideThreshold <= 0 || report.TargetType == ReportTargetUser {
+			return nil
+		}
+		query = `SELECT COUNT(*) FROM reports WHERE target_type = $1 AND target_id = $2 AND status = 'open'`
+		var open int
+		if err := tx.QueryRowContext(ctx, query, report.TargetType, report.TargetID).Scan(&open); err != nil {
+			return err
+		}
+		if open < hideThreshold {
+			return nil
+		}
+		hidden, err := setHidden(ctx, tx, report.TargetType, report.TargetID, true)
+		if err != nil || !hidden {
+			return err
+		}
+		return recordModerationAction(ctx, tx, &ModerationAction{
+			ReportID:   &report.ID,
+			Action:     ModerationHide,
+			TargetType: report.TargetType,
+			TargetID:   report.TargetID,
+			SubjectID:  report.SubjectID,
+			Note:       fmt.Sprintf("hidden automatically after %d reports", open),
+		})
+	})
+}
+
+// flagContent files a report on behalf of the content filter, with no
+// reporter, as part of the write that saves the flagged content. Nothing is
+// filed when details is empty, and content that already has an open flag is
+// left with that one.
+func flagContent(ctx context.Context, tx *sql.Tx, targetType string, targetID, subjectID int64, details string) error {
+	if details == "" {
+		return nil
+	}
+	query := `INSERT INTO reports (target_type, target_id, subject_id, reason, details)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (target_type, target_id) WHERE status = 'open' AND reporter_id IS NULL DO NOTHING`
+	_, err := tx.ExecContext(ctx, query, targetType, targetID, subjectID, ReportReasonAutomated, details)
+	return err
+}
+
+// CountRepeats counts the posts or comments, as targetType says, that userID
+// created since since with the content fingerprint, for the content
+// filter. Deleted posts count too.
+func (s *ReportStore) CountRepeats(ctx context.Context, targetType string, userID int64, fingerprint []byte, since time.Time) (int, error) {
+	var table string
+	switch targetType {
+	case ReportTargetPost:
+		table = "posts"
+	case ReportTargetComment:
+		table = "comments"
+	default:
+		return 0, fmt.Errorf("cannot count repeats of %q", targetType)
+	}
+	query := `SELECT COUNT(*) FROM ` + table + ` WHERE user_id = $1 AND content_fingerprint = $2 AND created_at > $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID, fingerprint, since).Scan(&count)
+	return count, err
+}
+
+// reportSubject returns the author of the target of a report, provided
+// reporterID may see it.
+func reportSubject(ctx context.Context, tx *sql.Tx, targetType string, targetID, reporterID int64) (int64, error) {
+	var subjectID int64
+	var err error
+	switch targetType {
+	case ReportTargetPost:
+		err = tx.QueryRowContext(ctx, `SELECT user_id FROM posts WHERE id = $1`, targetID).Scan(&subjectID)
+		if err == nil {
+			var visible bool
+			if visible, err = postVisibleTo(ctx, tx, targetID, reporterID); err == nil && !visible {
+				err = sql.ErrNoRows
+			}
+		}
+	case ReportTargetComment:
+		var postID int64
+		query := `SELECT user_id, post_id FROM comments WHERE id = $1 AND (hidden_at IS NULL OR user_id = $2)`
+		err = tx.QueryRowContext(ctx, query, targetID, reporterID).Scan(&subjectID, &postID)
+		if err == nil {
+			var visible bool
+			if visible, err = postVisibleTo(ctx, tx, postID, reporterID); err == nil && !visible {
+				err = sql.ErrNoRows
+			}
+		}
+	default:
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1`, targetID).Scan(&subjectID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+	return subjectID, nil
+}
+
+// setHidden hides or unhides a post or comment and reports whether that
+// changed anything.
+func setHidden(ctx context.Context, tx *sql.Tx, targetType string, targetID int64, hidden bool) (bool, error) {
+	table := "posts"
+	if targetType == ReportTargetComment {
+		table = "comments"
+	}
+	query := `UPDATE ` + table + ` SET hidden_at = CASE WHEN $2 THEN NOW() END
+	WHERE id = $1 AND (hidden_at IS NULL) = $2`
+	res, err := tx.ExecContext(ctx, query, targetID, hidden)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func recordModerationAction(ctx context.Context, tx *sql.Tx, action *ModerationAction) error {
+	query := `INSERT INTO moderation_actions (report_id, moderator_id, action, target_type, target_id, subject_id, note)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at`
+	return tx.QueryRowContext(ctx, query, action.ReportID, action.ModeratorID, action.Action, action.TargetType, action.TargetID, action.SubjectID, action.Note).Scan(&action.ID, &action.CreatedAt)
+}
+
+// GetQueue returns a page of reports matching rq, oldest first, so the
+// longest waiting reports are handled first.
+func (s *ReportStore) GetQueue(ctx context.Context, rq ReportQuery) ([]Report, error) {
+	query := `SELECT ` + reportColumns + `
+	FROM reports r
+	WHERE ($1 = '' OR r.status = $1) AND
+		($2 = '' OR r.target_type = $2) AND
+		($3 = '' OR r.reason = $3) AND
+		(NOT $4 OR r.assignee_id IS NULL) AND
+		($5 = 0 OR r.assignee_id = $5)
+	ORDER BY r.id
+	LIMIT $6 OFFSET $7`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, rq.Status, rq.TargetType, rq.Reason, rq.Unassigned, rq.AssigneeID, rq.Limit, rq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var r Report
+		if err := scanReport(rows, &r); err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// GetByID returns a report with the actions taken on it.
+func (s *ReportStore) GetByID(ctx context.Context, reportID int64) (*Report, error) {
+	query := `SELECT ` + reportColumns + ` FROM reports r WHERE r.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var r Report
+	if err := scanReport(s.db.QueryRowContext(ctx, query, reportID), &r); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	query = `SELECT id, report_id, moderator_id, action, target_type, target_id, subject_id, note, created_at
+	FROM moderation_actions WHERE report_id = $1 ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r.Actions = []ModerationAction{}
+	for rows.Next() {
+		var a ModerationAction
+		if err := rows.Scan(&a.ID, &a.ReportID, &a.ModeratorID, &a.Action, &a.TargetType, &a.TargetID, &a.SubjectID, &a.Note, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Actions = append(r.Actions, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Assign hands a report to a moderator, or back to the queue when
+// assigneeID is nil. It returns ErrNotFound when there is no such report and
+// ErrNotModerator when the assignee is not a moderator or admin.
+func (s *ReportStore) Assign(ctx context.Context, reportID int64, assigneeID *int64) error {
+	query := `UPDATE reports SET assignee_id = $2, updated_at = NOW()
+	WHERE id = $1 AND ($2::bigint IS NULL OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND role IN ('moderator', 'admin')))
+	RETURNING id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := s.db.QueryRowContext(ctx, query, reportID, assigneeID).Scan(&reportID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		var exists bool
+		if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM reports WHERE id = $1)`, reportID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrNotModerator
+		}
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Act carries out action on the target of an open report and closes every
+// open report on that target: dismissing them makes hidden content visible
+// again, hide hides the post or comment, warn notifies its author and
+// suspend suspends them until suspendUntil, or indefinitely when it is nil.
+// It returns ErrNotFound when there is no such report, ErrReportClosed when
+// it is no longer open, ErrInvalidAction when hiding a user and
+// ErrAlreadySuspended when suspending a user who is already suspended.
+func (s *ReportStore) Act(ctx context.Context, action *ModerationAction, suspendUntil *time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditReportActedOn, AuditTargetReport, "reports", *action.ReportID, func() error {
+			var status, reason string
+			query := `SELECT target_type, target_id, subject_id, status, reason FROM reports WHERE id = $1 FOR UPDATE`
+			err := tx.QueryRowContext(ctx, query, *action.ReportID).Scan(&action.TargetType, &action.TargetID, &action.SubjectID, &status, &reason)
+			if err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return ErrNotFound
+				default:
+					return err
+				}
+			}
+			if status != ReportStatusOpen {
+				return ErrReportClosed
+			}
+
+			switch action.Action {
+			case ModerationDismiss:
+				if action.TargetType != ReportTargetUser {
+					if _, err := setHidden(ctx, tx, action.TargetType, action.TargetID, false); err != nil {
+						return err
+					}
+				}
+			case ModerationHide:
+				if action.TargetType == ReportTargetUser {
+					return ErrInvalidAction
+				}
+				if _, err := setHidden(ctx, tx, action.TargetType, action.TargetID, true); err != nil {
+					return err
+				}
+			case ModerationWarn:
+				if err := warnUser(ctx, tx, action); err != nil {
+					return err
+				}
+			case ModerationSuspend:
+				suspension := &Suspension{
+					UserID:    action.SubjectID,
+					Reason:    action.Note,
+					IssuedBy:  action.ModeratorID,
+					ReportID:  action.ReportID,
+					ExpiresAt: suspendUntil,
+				}
+				if suspension.Reason == "" {
+					suspension.Reason = reason
+				}
+				if err := createSuspension(ctx, tx, suspension); err != nil {
+					return err
+				}
+			}
+			if err := recordModerationAction(ctx, tx, action); err != nil {
+				return err
+			}
+
+			resolution := ReportStatusResolved
+			if action.Action == ModerationDismiss {
+				resolution = ReportStatusDismissed
+			}
+			query = `UPDATE reports SET status = $3, resolved_by = $4, resolved_at = NOW(), updated_at = NOW()
+			WHERE target_type = $1 AND target_id = $2 AND status = 'open'`
+			_, err = tx.ExecContext(ctx, query, action.TargetType, action.TargetID, resolution, action.ModeratorID)
+			return err
+		})
+	})
+}
+
+// warnUser notifies the subject of action that a moderator acted on their
+// content. The notification names the user themselves as its actor, so
+// moderators stay anonymous.
+func warnUser(ctx context.Context, tx *sql.Tx, action *ModerationAction) error {
+	n := &Notification{
+		UserID:  action.SubjectID,
+		ActorID: action.SubjectID,
+		Type:    NotificationWarning,
+	}
+	switch action.TargetType {
+	case ReportTargetPost:
+		n.PostID = &action.TargetID
+	case ReportTargetComment:
+		n.CommentID = &action.TargetID
+		n.PostID = new(int64)
+		if err := tx.QueryRowContext(ctx, `SELECT post_id FROM comments WHERE id = $1`, action.TargetID).Scan(n.PostID); err != nil {
+			return err
+		}
+	}
+	return createNotification(ctx, tx, n)
+}
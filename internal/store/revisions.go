@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// PostRevision is one version of a post's editable fields. CreatedAt is when
+// the version was written; the current version has no ReplacedAt.
+type PostRevision struct {
+	PostID     int64   `json:"post_id"`
+	Version    int64   `json:"version"`
+	Title      string  `json:"title"`
+	Content    string  `json:"content"`
+	Visibility string  `json:"visibility"`
+	CreatedAt  string  `json:"created_at"`
+	ReplacedAt *string `json:"replaced_at"`
+	Current    bool    `json:"current"`
+}
+
+// revisionsQuery selects every version of post $1: the stored revisions and
+// the post itself as the current one.
+const revisionsQuery = `SELECT post_id, version, title, content, visibility, created_at, replaced_at, false FROM post_revisions WHERE post_id = $1
+	UNION ALL
+	SELECT id, version, title, content, visibility, updated_at, NULL, true FROM posts WHERE id = $1`
+
+// GetRevisions returns every version of a post, newest first.
+func (p *PostStore) GetRevisions(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `SELECT * FROM (` + revisionsQuery + `) r ORDER BY version DESC`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := p.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var r PostRevision
+		if err := rows.Scan(&r.PostID, &r.Version, &r.Title, &r.Content, &r.Visibility, &r.CreatedAt, &r.ReplacedAt, &r.Current); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision returns one version of a post, or ErrNotFound when there is no
+// such version.
+func (p *PostStore) GetRevision(ctx context.Context, postID, version int64) (*PostRevision, error) {
+	query := `SELECT * FROM (` + revisionsQuery + `) r WHERE version = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var r PostRevision
+	err := p.db.QueryRowContext(ctx, query, postID, version).Scan(&r.PostID, &r.Version, &r.Title, &r.Content, &r.Visibility, &r.CreatedAt, &r.ReplacedAt, &r.Current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &r, nil
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 )
@@ -10,6 +11,12 @@ import (
 var (
 	ErrNotFound          = errors.New("resource not found")
 	ErrConflict          = errors.New("resource already exists")
+	ErrVersionConflict   = errors.New("resource was modified by another request")
+	ErrBlocked           = errors.New("user is blocked")
+	ErrInvalidMedia      = errors.New("media not found or already attached")
+	ErrPrivateAccount    = errors.New("account is private")
+	ErrInvalidQuote      = errors.New("quoted post not found")
+	ErrNotShareable      = errors.New("only public posts from public accounts can be reposted")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -17,29 +24,166 @@ type Storage struct {
 	Posts interface {
 		GetByID(context.Context, int64) (*Post, error)
 		Create(context.Context, *Post) error
-		Delete(context.Context, int64) error
+		Delete(context.Context, *Post) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		IsVisibleTo(ctx context.Context, postID, viewerID int64) (bool, error)
+		GetFeedSince(ctx context.Context, userID int64, pos Position, limit int) ([]PostWithMetadata, error)
+		Repost(ctx context.Context, userID, postID int64) (*Post, error)
+		Unrepost(ctx context.Context, userID, postID int64) error
+		LoadEmbedded(ctx context.Context, viewerID int64, posts []*Post) error
+		GetRevisions(ctx context.Context, postID int64) ([]PostRevision, error)
+		GetRevision(ctx context.Context, postID, version int64) (*PostRevision, error)
+		GetTrash(ctx context.Context, userID int64, tq TrashQuery) ([]TrashedPost, error)
+		Restore(ctx context.Context, userID, postID int64) error
+		PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, []string, error)
+		GetDrafts(ctx context.Context, userID int64, dq DraftQuery) ([]Post, error)
+		PublishDue(ctx context.Context, limit int) ([]*Post, error)
 	}
 	Users interface {
 		Create(context.Context, *User) error
+		CreateWithRole(ctx context.Context, user *User, role string) error
 		GetByID(context.Context, int64) (*User, error)
+		Update(context.Context, *User) error
+		SetPrivate(ctx context.Context, userID int64, isPrivate bool) error
+		SetRole(ctx context.Context, userID int64, role string) error
+		SetPassword(ctx context.Context, userID int64, password []byte) error
+		Search(context.Context, AdminUserQuery) ([]User, error)
+		SetVerified(ctx context.Context, userID int64, verified bool) error
+		SetActive(ctx context.Context, userID int64, active bool) error
 	}
 	Comments interface {
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64) ([]Comment, error)
 		Create(context.Context, *Comment) error
+		GetSince(ctx context.Context, postIDs []int64, viewerID int64, pos Position, limit int) ([]Comment, error)
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID int64) error
 		Unfollow(ctx context.Context, followerID, userID int64) error
+		IsFollowing(ctx context.Context, followerID, userID int64) (bool, error)
+		GetFollowingIDs(ctx context.Context, followerID int64) ([]int64, error)
+	}
+	FollowRequests interface {
+		Create(context.Context, *FollowRequest) error
+		GetPending(ctx context.Context, userID int64) ([]FollowRequest, error)
+		Approve(ctx context.Context, userID, requesterID int64) error
+		Reject(ctx context.Context, userID, requesterID int64) error
+	}
+	Media interface {
+		Create(context.Context, *Media) error
+		GetByID(context.Context, int64) (*Media, error)
+		GetByPostID(context.Context, int64) ([]Media, error)
+		ClaimPending(context.Context) (*Media, error)
+		MarkReady(context.Context, *Media) error
+		MarkFailed(context.Context, *Media) error
+	}
+	Notifications interface {
+		GetGroups(ctx context.Context, userID int64, nq NotificationQuery) ([]NotificationGroup, error)
+		MarkRead(ctx context.Context, userID int64, ids []int64, upTo int64) (int64, error)
+		UnreadCount(ctx context.Context, userID int64) (int64, error)
+		GetSince(ctx context.Context, userID int64, pos Position, limit int) ([]Notification, error)
+	}
+	Messages interface {
+		Send(context.Context, *Message) error
+		GetConversations(ctx context.Context, userID int64, mq MessageQuery) ([]Conversation, error)
+		GetMessages(ctx context.Context, conversationID, userID int64, mq MessageQuery) ([]Message, error)
+		GetSince(ctx context.Context, userID int64, pos Position, limit int) ([]Message, error)
+		SyncCursors(ctx context.Context, userID int64) (messages, receipts Position, err error)
+		GetReceiptsSince(ctx context.Context, userID int64, pos Position, limit int) ([]Receipt, error)
+		MarkDelivered(ctx context.Context, conversationID, userID, upTo int64) (*Receipt, error)
+		MarkRead(ctx context.Context, conversationID, userID, upTo int64) (*Receipt, error)
+	}
+	Bookmarks interface {
+		Save(ctx context.Context, userID, postID int64, collectionID *int64) error
+		Remove(ctx context.Context, userID, postID int64) error
+		IsBookmarked(ctx context.Context, userID, postID int64) (bool, error)
+		Get(ctx context.Context, userID int64, bq BookmarkQuery) ([]Bookmark, error)
+		CreateCollection(context.Context, *BookmarkCollection) error
+		GetCollections(ctx context.Context, userID int64) ([]BookmarkCollection, error)
+		RenameCollection(context.Context, *BookmarkCollection) error
+		DeleteCollection(ctx context.Context, userID, collectionID int64) error
+	}
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int64) error
+		Unblock(ctx context.Context, blockerID, blockedID int64) error
+	}
+	Webhooks interface {
+		Create(context.Context, *Webhook) error
+		GetByUserID(ctx context.Context, userID int64) ([]Webhook, error)
+		GetByID(ctx context.Context, userID, webhookID int64) (*Webhook, error)
+		Update(context.Context, *Webhook) error
+		Delete(ctx context.Context, userID, webhookID int64) error
+		CreateDeliveries(ctx context.Context, eventID int64, eventType string, userIDs []int64, payload json.RawMessage) (int, error)
+		GetDelivery(ctx context.Context, deliveryID int64) (*WebhookDelivery, *Webhook, error)
+		GetDeliveries(ctx context.Context, userID, webhookID int64, wq WebhookDeliveryQuery) ([]WebhookDelivery, error)
+		RecordAttempt(ctx context.Context, attempt *WebhookAttempt, final bool, disableAfter int) (bool, error)
+		Redeliver(ctx context.Context, userID, webhookID, deliveryID int64) error
+	}
+	Reports interface {
+		Create(ctx context.Context, report *Report, hideThreshold int) error
+		GetQueue(context.Context, ReportQuery) ([]Report, error)
+		GetByID(context.Context, int64) (*Report, error)
+		Assign(ctx context.Context, reportID int64, assigneeID *int64) error
+		Act(ctx context.Context, action *ModerationAction, suspendUntil *time.Time) error
+		CountRepeats(ctx context.Context, targetType string, userID int64, fingerprint []byte, since time.Time) (int, error)
+	}
+	Suspensions interface {
+		Create(context.Context, *Suspension) error
+		Lift(ctx context.Context, userID int64, liftedBy *int64) (*Suspension, error)
+		GetActive(ctx context.Context, userID int64) (*Suspension, error)
+		GetAccountStatus(ctx context.Context, userID int64) (*AccountStatus, error)
+		List(context.Context, SuspensionQuery) ([]Suspension, error)
+	}
+	Audit interface {
+		Get(context.Context, AuditQuery) ([]AuditEntry, error)
+	}
+	Admin interface {
+		GetUserPosts(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminPost, error)
+		GetUserComments(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminComment, error)
+		GetFollowers(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminFollow, error)
+		GetFollowing(ctx context.Context, userID int64, aq AdminListQuery) ([]AdminFollow, error)
+		DeleteContent(ctx context.Context, postIDs, commentIDs []int64) (*DeletedContent, error)
+		GetDailyStats(context.Context, StatsQuery) ([]DailyStats, error)
+	}
+	Mutes interface {
+		Mute(ctx context.Context, muterID, mutedID int64) error
+		Unmute(ctx context.Context, muterID, mutedID int64) error
+	}
+	Streams interface {
+		Start(context.Context) (Position, error)
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStore{db},
-		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
-		Followers: &FollowerStore{db},
+		Posts:          &PostStore{db},
+		Users:          &UserStore{db},
+		Comments:       &CommentStore{db},
+		Followers:      &FollowerStore{db},
+		FollowRequests: &FollowRequestStore{db},
+		Blocks:         &BlockStore{db},
+		Mutes:          &MuteStore{db},
+		Media:          &MediaStore{db},
+		Notifications:  &NotificationStore{db},
+		Messages:       &MessageStore{db},
+		Bookmarks:      &BookmarkStore{db},
+		Webhooks:       &WebhookStore{db},
+		Reports:        &ReportStore{db},
+		Suspensions:    &SuspensionStore{db},
+		Audit:          &AuditStore{db},
+		Admin:          &AdminStore{db},
+		Streams:        &StreamStore{db},
+	}
+}
+
+func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
 	}
+	return tx.Commit()
 }
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+)
+
+// Position is a place in a stream of rows read in the order their
+// transactions committed, approximated by transaction ID then row ID. A
+// reader only sees rows written by transactions older than every one still
+// running, so a row committed late by a slow transaction is never skipped
+// by a cursor that has already moved past a newer one.
+type Position struct {
+	TxID int64
+	ID   int64
+}
+
+// committedAfter returns a WHERE clause fragment matching rows past the
+// position held in the txArg and idArg parameters whose writing transaction
+// has finished, along with every transaction before it.
+func committedAfter(txColumn, idColumn, txArg, idArg string) string {
+	return `(` + txColumn + `, ` + idColumn + `) > (` + txArg + `, ` + idArg + `) AND ` +
+		txColumn + ` < txid_snapshot_xmin(txid_current_snapshot())`
+}
+
+// byCommit orders rows the way committedAfter compares them.
+func byCommit(txColumn, idColumn string) string {
+	return txColumn + `, ` + idColumn
+}
+
+type StreamStore struct {
+	db *sql.DB
+}
+
+// Start returns the position just before the oldest transaction still
+// running, from which a new stream receives only what is committed next.
+func (s *StreamStore) Start(ctx context.Context) (Position, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	pos := Position{ID: math.MaxInt64}
+	err := s.db.QueryRowContext(ctx, `SELECT txid_snapshot_xmin(txid_current_snapshot()) - 1`).Scan(&pos.TxID)
+	return pos, err
+}
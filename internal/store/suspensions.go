@@ -0,0 +1,225 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrAlreadySuspended = errors.New("user is already suspended")
+	ErrNotSuspended     = errors.New("user is not suspended")
+)
+
+// Suspension stops a user from using their account until it expires or is
+// lifted. A nil ExpiresAt suspends them indefinitely, which is a ban.
+type Suspension struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Reason    string     `json:"reason"`
+	IssuedBy  *int64     `json:"issued_by"`
+	ReportID  *int64     `json:"report_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	LiftedAt  *time.Time `json:"lifted_at"`
+	LiftedBy  *int64     `json:"lifted_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Active is set while the suspension is in force.
+	Active bool `json:"active"`
+}
+
+type SuspensionStore struct {
+	db *sql.DB
+}
+
+// suspensionInForce is a WHERE clause fragment matching the suspensions s
+// that are in force.
+const suspensionInForce = `s.lifted_at IS NULL AND (s.expires_at IS NULL OR s.expires_at > NOW())`
+
+// notSuspended returns a WHERE clause fragment matching rows whose user,
+// in column, is not suspended.
+func notSuspended(column string) string {
+	return `NOT EXISTS (SELECT 1 FROM suspensions s WHERE s.user_id = ` + column + ` AND ` + suspensionInForce + `)`
+}
+
+const suspensionColumns = `s.id, s.user_id, s.reason, s.issued_by, s.report_id, s.expires_at, s.lifted_at, s.lifted_by, s.created_at, (` + suspensionInForce + `)`
+
+func scanSuspension(row interface{ Scan(...any) error }, s *Suspension) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Reason, &s.IssuedBy, &s.ReportID, &s.ExpiresAt, &s.LiftedAt, &s.LiftedBy, &s.CreatedAt, &s.Active)
+}
+
+// Create suspends suspension.UserID. It returns ErrNotFound when there is
+// no such user and ErrAlreadySuspended while they have a suspension in
+// force.
+func (s *SuspensionStore) Create(ctx context.Context, suspension *Suspension) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return createSuspension(ctx, tx, suspension)
+	})
+}
+
+// createSuspension records suspension as part of tx. The user's row is
+// locked so two moderators cannot suspend them at once.
+func createSuspension(ctx context.Context, tx *sql.Tx, suspension *Suspension) error {
+	var userID int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, suspension.UserID).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	query := `INSERT INTO suspensions (user_id, reason, issued_by, report_id, expires_at)
+	SELECT $1, $2, $3, $4, $5
+	WHERE ` + notSuspended("$1") + `
+	RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query, suspension.UserID, suspension.Reason, suspension.IssuedBy, suspension.ReportID, suspension.ExpiresAt).Scan(&suspension.ID, &suspension.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrAlreadySuspended
+		default:
+			return err
+		}
+	}
+	suspension.Active = true
+	after, err := snapshot(ctx, tx, "suspensions", suspension.ID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, AuditUserSuspended, AuditTargetUser, suspension.UserID, nil, after)
+}
+
+// Lift ends the suspension in force on userID early, recording who lifted
+// it, if anyone did. It returns ErrNotSuspended when there is none.
+func (s *SuspensionStore) Lift(ctx context.Context, userID int64, liftedBy *int64) (*Suspension, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var suspension Suspension
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT s.id FROM suspensions s WHERE s.user_id = $1 AND ` + suspensionInForce + ` FOR UPDATE`
+		var id int64
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&id); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotSuspended
+			default:
+				return err
+			}
+		}
+		before, err := snapshot(ctx, tx, "suspensions", id)
+		if err != nil {
+			return err
+		}
+		query = `UPDATE suspensions s SET lifted_at = NOW(), lifted_by = $2 WHERE s.id = $1
+		RETURNING ` + suspensionColumns
+		if err := scanSuspension(tx.QueryRowContext(ctx, query, id, liftedBy), &suspension); err != nil {
+			return err
+		}
+		after, err := snapshot(ctx, tx, "suspensions", id)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, AuditSuspensionLifted, AuditTargetUser, userID, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &suspension, nil
+}
+
+// GetActive returns the suspension in force on userID, or ErrNotFound when
+// they are not suspended.
+func (s *SuspensionStore) GetActive(ctx context.Context, userID int64) (*Suspension, error) {
+	query := `SELECT ` + suspensionColumns + ` FROM suspensions s
+	WHERE s.user_id = $1 AND ` + suspensionInForce + `
+	ORDER BY s.id DESC LIMIT 1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var suspension Suspension
+	if err := scanSuspension(s.db.QueryRowContext(ctx, query, userID), &suspension); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &suspension, nil
+}
+
+// AccountStatus is whether a user may use their account.
+type AccountStatus struct {
+	Deactivated bool
+	// Suspension is the suspension in force, if any, with only its ID,
+	// user, reason and expiry set.
+	Suspension *Suspension
+}
+
+// GetAccountStatus reports in a single query whether userID has
+// deactivated their account or is suspended. It returns ErrNotFound when
+// there is no such user.
+func (s *SuspensionStore) GetAccountStatus(ctx context.Context, userID int64) (*AccountStatus, error) {
+	query := `SELECT u.deactivated_at IS NOT NULL, s.id, s.reason, s.expires_at
+	FROM users u
+	LEFT JOIN LATERAL (
+		SELECT s.id, s.reason, s.expires_at FROM suspensions s
+		WHERE s.user_id = u.id AND ` + suspensionInForce + `
+		ORDER BY s.id DESC LIMIT 1
+	) s ON true
+	WHERE u.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var status AccountStatus
+	var suspensionID sql.NullInt64
+	var reason sql.NullString
+	var expiresAt *time.Time
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&status.Deactivated, &suspensionID, &reason, &expiresAt); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	if suspensionID.Valid {
+		status.Suspension = &Suspension{
+			ID:        suspensionID.Int64,
+			UserID:    userID,
+			Reason:    reason.String,
+			ExpiresAt: expiresAt,
+			Active:    true,
+		}
+	}
+	return &status, nil
+}
+
+// List returns a page of suspensions matching sq, newest first.
+func (s *SuspensionStore) List(ctx context.Context, sq SuspensionQuery) ([]Suspension, error) {
+	query := `SELECT ` + suspensionColumns + ` FROM suspensions s
+	WHERE ($1 = 0 OR s.user_id = $1) AND
+		(NOT $2 OR (` + suspensionInForce + `))
+	ORDER BY s.id DESC
+	LIMIT $3 OFFSET $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, sq.UserID, sq.Active, sq.Limit, sq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suspensions := []Suspension{}
+	for rows.Next() {
+		var suspension Suspension
+		if err := scanSuspension(rows, &suspension); err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, suspension)
+	}
+	return suspensions, rows.Err()
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TrashedPost is a deleted post that its author can still restore until it
+// is purged at PurgeAt.
+type TrashedPost struct {
+	Post
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// GetTrash returns a page of userID's deleted posts, most recently deleted
+// first.
+func (p *PostStore) GetTrash(ctx context.Context, userID int64, tq TrashQuery) ([]TrashedPost, error) {
+	query := `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.visibility, u.username,
+		p.reposted_post_id, p.quoted_post_id, p.status, p.publish_at, p.deleted_at
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE p.user_id = $1 AND p.deleted_at IS NOT NULL
+	ORDER BY p.deleted_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := p.db.QueryContext(ctx, query, userID, tq.Limit, tq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := []TrashedPost{}
+	for rows.Next() {
+		var t TrashedPost
+		err := rows.Scan(&t.ID, &t.USERID, &t.Title, &t.Content, pq.Array(&t.Tags), &t.CreatedAt, &t.UpdatedAt, &t.Version, &t.Visibility, &t.User.Username,
+			&t.RepostedPostID, &t.QuotedPostID, &t.Status, &t.PublishAt, &t.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		t.Edited = t.Version > 0
+		trash = append(trash, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]*Post, len(trash))
+	for i := range trash {
+		posts[i] = &trash[i].Post
+	}
+	if err := loadPostMentions(ctx, p.db, posts); err != nil {
+		return nil, err
+	}
+	if err := loadEmbeddedPosts(ctx, p.db, userID, posts); err != nil {
+		return nil, err
+	}
+	return trash, nil
+}
+
+// Restore takes one of userID's posts out of the trash. It returns
+// ErrNotFound when they have no such deleted post and ErrConflict when it is
+// a repost of a post they have reposted again since.
+func (p *PostStore) Restore(ctx context.Context, userID, postID int64) error {
+	query := `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+	RETURNING status, version, reposted_post_id, quoted_post_id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditPostRestored, AuditTargetPost, "posts", postID, func() error {
+			post := &Post{ID: postID, USERID: userID}
+			err := tx.QueryRowContext(ctx, query, postID, userID).Scan(&post.Status, &post.Version, &post.RepostedPostID, &post.QuotedPostID)
+			if err != nil {
+				if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+					return ErrConflict
+				}
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return ErrNotFound
+				default:
+					return err
+				}
+			}
+			return recordEvent(ctx, tx, EventPostRestored, postEvent(post))
+		})
+	})
+}
+
+// PurgeDeleted permanently removes up to limit posts deleted before the
+// given time. Their comments, mentions, notifications, bookmarks, revisions,
+// reposts and media rows go with them through foreign keys. It returns how
+// many posts were purged and the storage keys of the media files that are no
+// longer referenced, which the caller must remove from blob storage.
+func (p *PostStore) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var ids []int64
+	keys := []string{}
+	err := withTx(p.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT id, user_id FROM posts
+		WHERE deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`
+		rows, err := tx.QueryContext(ctx, query, before, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		purged := []PostEvent{}
+		for rows.Next() {
+			var e PostEvent
+			if err := rows.Scan(&e.PostID, &e.UserID); err != nil {
+				return err
+			}
+			ids = append(ids, e.PostID)
+			purged = append(purged, e)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		for _, e := range purged {
+			if err := recordEvent(ctx, tx, EventPostPurged, e); err != nil {
+				return err
+			}
+		}
+
+		query = `SELECT storage_key FROM media WHERE post_id = ANY($1)
+		UNION ALL
+		SELECT v.storage_key FROM media_variants v JOIN media m ON m.id = v.media_id WHERE m.post_id = ANY($1)`
+		rows, err = tx.QueryContext(ctx, query, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE id = ANY($1)`, pq.Array(ids))
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(ids), keys, nil
+}
@@ -3,37 +3,116 @@ package store
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 )
 
 type User struct {
-	ID        int64  `json:"id"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Password  string `json:"-"`
-	CreatedAt string `json:"created_at"`
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"-"`
+	IsPrivate   bool   `json:"is_private"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+	AvatarURL   string `json:"avatar_url"`
+	// Role is user, moderator or admin.
+	Role string `json:"role,omitempty"`
+	// VerifiedAt is when an admin verified the account. DeactivatedAt is
+	// set while an admin has the account deactivated, which shuts the user
+	// out like a ban until it is reactivated.
+	VerifiedAt    *time.Time `json:"verified_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	Version       int64      `json:"version"`
+	CreatedAt     string     `json:"created_at"`
+	UpdatedAt     string     `json:"updated_at"`
+}
+
+// PublicProfile is the view of a user shown to other users. It never
+// includes the email address.
+type PublicProfile struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	IsPrivate   bool   `json:"is_private"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	Location    string `json:"location"`
+	AvatarURL   string `json:"avatar_url"`
+	Verified    bool   `json:"verified"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func (u *User) PublicProfile() PublicProfile {
+	return PublicProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		IsPrivate:   u.IsPrivate,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		Website:     u.Website,
+		Location:    u.Location,
+		AvatarURL:   u.AvatarURL,
+		Verified:    u.VerifiedAt != nil,
+		CreatedAt:   u.CreatedAt,
+	}
 }
 
 type UserStore struct {
 	db *sql.DB
 }
 
+const userColumns = `id, username, email, password, is_private, display_name, bio, website, location, avatar_url, role, verified_at, deactivated_at, version, created_at, updated_at`
+
+func scanUser(row interface{ Scan(...any) error }, user *User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsPrivate, &user.DisplayName, &user.Bio, &user.Website, &user.Location, &user.AvatarURL, &user.Role, &user.VerifiedAt, &user.DeactivatedAt, &user.Version, &user.CreatedAt, &user.UpdatedAt)
+}
+
+const createUserQuery = `INSERT INTO USERS (username, email, password, is_private)
+	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
+
 func (u *UserStore) Create(ctx context.Context, user *User) error {
-	query :=
-		`INSERT INTO USERS (username, email, password)
-	VALUES ($1, $2, $3) RETURNING id, created_at`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := u.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Password).Scan(&user.ID, &user.CreatedAt)
+	err := u.db.QueryRowContext(ctx, createUserQuery, user.Username, user.Email, user.Password, user.IsPrivate).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	return err
 }
 
+// CreateWithRole creates user as a user, moderator or admin, in one
+// transaction, so a failure leaves no account with the wrong role behind.
+// Any role but user is audited as a role change.
+func (u *UserStore) CreateWithRole(ctx context.Context, user *User, role string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, createUserQuery, user.Username, user.Email, user.Password, user.IsPrivate).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		user.Role = RoleUser
+		if role == RoleUser {
+			return nil
+		}
+		return audited(ctx, tx, AuditUserRoleChanged, AuditTargetUser, "users", user.ID, func() error {
+			_, err := tx.ExecContext(ctx, `UPDATE users SET role=$1 WHERE id=$2`, role, user.ID)
+			if err != nil {
+				return err
+			}
+			user.Role = role
+			return nil
+		})
+	})
+}
+
 func (u *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
-	query := `SELECT id, username, email, password, created_at FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 	row := u.db.QueryRowContext(ctx, query, id)
 	var user User
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	err := scanUser(row, &user)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -44,3 +123,172 @@ func (u *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	}
 	return &user, nil
 }
+
+func (u *UserStore) Update(ctx context.Context, user *User) error {
+	query := `UPDATE users SET display_name=$1, bio=$2, website=$3, location=$4, avatar_url=$5, updated_at=NOW(), version=version+1
+	WHERE id=$6 AND version=$7 RETURNING version, updated_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditUserUpdated, AuditTargetUser, "users", user.ID, func() error {
+			err := tx.QueryRowContext(ctx, query, user.DisplayName, user.Bio, user.Website, user.Location, user.AvatarURL, user.ID, user.Version).Scan(&user.Version, &user.UpdatedAt)
+			if err != nil {
+				switch err {
+				case sql.ErrNoRows:
+					return ErrNotFound
+				default:
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, and the escape
+// character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Search returns a page of users whose username or email contains
+// uq.Search, oldest first. The search is literal: % and _ match only
+// themselves.
+func (u *UserStore) Search(ctx context.Context, uq AdminUserQuery) ([]User, error) {
+	query := `SELECT ` + userColumns + ` FROM users
+	WHERE (username ILIKE $1 ESCAPE '\' OR email ILIKE $1 ESCAPE '\') AND
+		($2 = '' OR role = $2)
+	ORDER BY id
+	LIMIT $3 OFFSET $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	pattern := "%" + likeEscaper.Replace(uq.Search) + "%"
+	rows, err := u.db.QueryContext(ctx, query, pattern, uq.Role, uq.Limit, uq.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// SetRole makes userID a user, moderator or admin. It returns ErrNotFound
+// when there is no such user.
+func (u *UserStore) SetRole(ctx context.Context, userID int64, role string) error {
+	query := `UPDATE users SET role=$1, updated_at=NOW() WHERE id=$2`
+	return u.update(ctx, AuditUserRoleChanged, userID, query, role, userID)
+}
+
+// SetPassword replaces userID's password with password, which should
+// already be hashed. It returns ErrNotFound when there is no such user.
+func (u *UserStore) SetPassword(ctx context.Context, userID int64, password []byte) error {
+	query := `UPDATE users SET password=$1, updated_at=NOW() WHERE id=$2`
+	return u.update(ctx, AuditUserPasswordReset, userID, query, password, userID)
+}
+
+// SetVerified verifies userID, or takes their verification away. It
+// returns ErrNotFound when there is no such user.
+func (u *UserStore) SetVerified(ctx context.Context, userID int64, verified bool) error {
+	query := `UPDATE users SET verified_at = CASE WHEN $1 THEN COALESCE(verified_at, NOW()) END, updated_at=NOW() WHERE id=$2`
+	action := AuditUserVerified
+	if !verified {
+		action = AuditUserUnverified
+	}
+	return u.update(ctx, action, userID, query, verified, userID)
+}
+
+// SetActive reactivates userID, or deactivates them. It returns ErrNotFound
+// when there is no such user.
+func (u *UserStore) SetActive(ctx context.Context, userID int64, active bool) error {
+	query := `UPDATE users SET deactivated_at = CASE WHEN NOT $1 THEN COALESCE(deactivated_at, NOW()) END, updated_at=NOW() WHERE id=$2`
+	action := AuditUserReactivated
+	if !active {
+		action = AuditUserDeactivated
+	}
+	return u.update(ctx, action, userID, query, active, userID)
+}
+
+// update runs query, which changes the user userID, and audits it as
+// action. It returns ErrNotFound when the query matches no user.
+func (u *UserStore) update(ctx context.Context, action string, userID int64, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, action, AuditTargetUser, "users", userID, func() error {
+			res, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return ErrNotFound
+			}
+			return nil
+		})
+	})
+}
+
+// SetPrivate changes whether userID is a private account. Making an account
+// public approves every follow request still pending on it, except from
+// users blocked by or blocking userID.
+func (u *UserStore) SetPrivate(ctx context.Context, userID int64, isPrivate bool) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditUserPrivacy, AuditTargetUser, "users", userID, func() error {
+			query := `UPDATE users SET is_private=$1 WHERE id=$2`
+			res, err := tx.ExecContext(ctx, query, isPrivate, userID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return ErrNotFound
+			}
+			if isPrivate {
+				return nil
+			}
+			query = `INSERT INTO followers (user_id, follower_id)
+			SELECT fr.user_id, fr.requester_id FROM follow_requests fr
+			WHERE fr.user_id=$1 AND
+				NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id=fr.user_id AND b.blocked_id=fr.requester_id) OR (b.blocker_id=fr.requester_id AND b.blocked_id=fr.user_id))
+			ON CONFLICT DO NOTHING
+			RETURNING follower_id`
+			rows, err := tx.QueryContext(ctx, query, userID)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			followed := []FollowEvent{}
+			for rows.Next() {
+				e := FollowEvent{UserID: userID}
+				if err := rows.Scan(&e.FollowerID); err != nil {
+					return err
+				}
+				followed = append(followed, e)
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			for _, e := range followed {
+				if err := recordEvent(ctx, tx, EventUserFollowed, e); err != nil {
+					return err
+				}
+			}
+			_, err = tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE user_id=$1`, userID)
+			return err
+		})
+	})
+}
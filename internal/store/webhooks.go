@@ -0,0 +1,417 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/jobs"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWebhookDisabled = errors.New("webhook is disabled")
+	ErrDeliveryPending = errors.New("delivery is still pending")
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// MaxWebhookAttempts is how often a delivery is tried before it fails.
+const MaxWebhookAttempts = 10
+
+// WebhookDeliveryJob sends one webhook delivery.
+const WebhookDeliveryJob jobs.Kind[WebhookDeliveryArgs] = "webhook.deliver"
+
+type WebhookDeliveryArgs struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+type Webhook struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	URL    string `json:"url"`
+	// Secret signs deliveries. It is only returned when the webhook is
+	// created.
+	Secret              string     `json:"secret,omitempty"`
+	EventTypes          []string   `json:"event_types"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID          int64           `json:"id"`
+	WebhookID   int64           `json:"webhook_id"`
+	EventID     int64           `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	DeliveredAt *time.Time      `json:"delivered_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	// History lists the attempts at this delivery, oldest first.
+	History []WebhookAttempt `json:"history"`
+}
+
+type WebhookAttempt struct {
+	ID             int64     `json:"id"`
+	DeliveryID     int64     `json:"delivery_id"`
+	ResponseStatus *int      `json:"response_status"`
+	Error          *string   `json:"error"`
+	DurationMS     int       `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Succeeded reports whether the receiver accepted the delivery.
+func (a *WebhookAttempt) Succeeded() bool {
+	return a.ResponseStatus != nil && *a.ResponseStatus >= 200 && *a.ResponseStatus < 300
+}
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+const webhookColumns = `id, user_id, url, event_types, active, consecutive_failures, disabled_at, created_at, updated_at`
+
+func scanWebhook(row interface{ Scan(...any) error }, w *Webhook) error {
+	return row.Scan(&w.ID, &w.UserID, &w.URL, pq.Array(&w.EventTypes), &w.Active, &w.ConsecutiveFailures, &w.DisabledAt, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *Webhook) error {
+	query := `INSERT INTO webhooks (user_id, url, secret, event_types)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ` + webhookColumns
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return scanWebhook(s.db.QueryRowContext(ctx, query, webhook.UserID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes)), webhook)
+}
+
+// GetByUserID returns userID's webhooks, oldest first.
+func (s *WebhookStore) GetByUserID(ctx context.Context, userID int64) ([]Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE user_id = $1 ORDER BY id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var w Webhook
+		if err := scanWebhook(rows, &w); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetByID returns one of userID's webhooks, or ErrNotFound.
+func (s *WebhookStore) GetByID(ctx context.Context, userID, webhookID int64) (*Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var w Webhook
+	if err := scanWebhook(s.db.QueryRowContext(ctx, query, webhookID, userID), &w); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &w, nil
+}
+
+// Update saves changes to one of webhook.UserID's webhooks, keeping the
+// secret when webhook.Secret is empty. Enabling a webhook starts its count
+// of failures over. It returns ErrNotFound when there is no such webhook.
+func (s *WebhookStore) Update(ctx context.Context, webhook *Webhook) error {
+	query := `UPDATE webhooks SET url = $1, event_types = $2, active = $3, secret = COALESCE(NULLIF($4, ''), secret),
+		consecutive_failures = CASE WHEN $3 AND NOT active THEN 0 ELSE consecutive_failures END,
+		disabled_at = CASE WHEN $3 THEN NULL ELSE COALESCE(disabled_at, NOW()) END,
+		updated_at = NOW()
+	WHERE id = $5 AND user_id = $6
+	RETURNING ` + webhookColumns
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditWebhookUpdated, AuditTargetWebhook, "webhooks", webhook.ID, func() error {
+			err := scanWebhook(tx.QueryRowContext(ctx, query, webhook.URL, pq.Array(webhook.EventTypes), webhook.Active, webhook.Secret, webhook.ID, webhook.UserID), webhook)
+			if err != nil {
+				switch {
+				case errors.Is(err, sql.ErrNoRows):
+					return ErrNotFound
+				default:
+					return err
+				}
+			}
+			webhook.Secret = ""
+			return nil
+		})
+	})
+}
+
+// Delete removes one of userID's webhooks along with its deliveries.
+func (s *WebhookStore) Delete(ctx context.Context, userID, webhookID int64) error {
+	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return audited(ctx, tx, AuditWebhookDeleted, AuditTargetWebhook, "webhooks", webhookID, func() error {
+			res, err := tx.ExecContext(ctx, query, webhookID, userID)
+			if err != nil {
+				return err
+			}
+			rowsAffected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rowsAffected == 0 {
+				return ErrNotFound
+			}
+			return nil
+		})
+	})
+}
+
+// CreateDeliveries queues payload for every active webhook of the given
+// users that subscribes to eventType and returns how many were queued. An
+// event already queued for a webhook is not queued again.
+func (s *WebhookStore) CreateDeliveries(ctx context.Context, eventID int64, eventType string, userIDs []int64, payload json.RawMessage) (int, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+	SELECT id, $1, $2, $3 FROM webhooks
+	WHERE user_id = ANY($4) AND active AND $2 = ANY(event_types)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING
+	RETURNING id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var ids []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, eventID, eventType, []byte(payload), pq.Array(userIDs))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if err := enqueueDelivery(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func enqueueDelivery(ctx context.Context, tx *sql.Tx, deliveryID int64) error {
+	_, err := WebhookDeliveryJob.Enqueue(ctx, tx, WebhookDeliveryArgs{DeliveryID: deliveryID}, jobs.EnqueueOptions{
+		MaxAttempts: MaxWebhookAttempts,
+		UniqueKey:   strconv.FormatInt(deliveryID, 10),
+	})
+	if errors.Is(err, jobs.ErrDuplicate) {
+		return ErrDeliveryPending
+	}
+	return err
+}
+
+// GetDelivery returns a delivery along with the webhook it is for.
+func (s *WebhookStore) GetDelivery(ctx context.Context, deliveryID int64) (*WebhookDelivery, *Webhook, error) {
+	query := `SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.delivered_at, d.created_at, d.updated_at,
+		w.id, w.user_id, w.url, w.secret, w.event_types, w.active, w.consecutive_failures, w.disabled_at, w.created_at, w.updated_at
+	FROM webhook_deliveries d
+	JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var d WebhookDelivery
+	var w Webhook
+	err := s.db.QueryRowContext(ctx, query, deliveryID).Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt,
+		&w.ID, &w.UserID, &w.URL, &w.Secret, pq.Array(&w.EventTypes), &w.Active, &w.ConsecutiveFailures, &w.DisabledAt, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+	return &d, &w, nil
+}
+
+// GetDeliveries returns a page of the deliveries of one of userID's
+// webhooks with their attempts, newest first.
+func (s *WebhookStore) GetDeliveries(ctx context.Context, userID, webhookID int64, wq WebhookDeliveryQuery) ([]WebhookDelivery, error) {
+	query := `SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.delivered_at, d.created_at, d.updated_at
+	FROM webhook_deliveries d
+	JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.webhook_id = $1 AND w.user_id = $2 AND ($5 = '' OR d.status = $5)
+	ORDER BY d.id DESC
+	LIMIT $3 OFFSET $4`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, query, webhookID, userID, wq.Limit, wq.Offset, wq.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	byID := map[int64]*WebhookDelivery{}
+	ids := []int64{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		d.History = []WebhookAttempt{}
+		deliveries = append(deliveries, d)
+		ids = append(ids, d.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for i := range deliveries {
+		byID[deliveries[i].ID] = &deliveries[i]
+	}
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	query = `SELECT id, delivery_id, response_status, error, duration_ms, created_at
+	FROM webhook_delivery_attempts WHERE delivery_id = ANY($1) ORDER BY id`
+	rows, err = s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a WebhookAttempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.ResponseStatus, &a.Error, &a.DurationMS, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		d := byID[a.DeliveryID]
+		d.History = append(d.History, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt saves an attempt at a delivery and updates the delivery and
+// its webhook. A successful attempt completes the delivery; a failed one
+// fails it when final is set. After disableAfter failed attempts in a row
+// the webhook is disabled and its pending deliveries fail. It reports
+// whether the webhook is still active.
+func (s *WebhookStore) RecordAttempt(ctx context.Context, attempt *WebhookAttempt, final bool, disableAfter int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	succeeded := attempt.Succeeded()
+	status := WebhookDeliveryPending
+	switch {
+	case succeeded:
+		status = WebhookDeliverySucceeded
+	case final:
+		status = WebhookDeliveryFailed
+	}
+	active := false
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO webhook_delivery_attempts (delivery_id, response_status, error, duration_ms)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+		err := tx.QueryRowContext(ctx, query, attempt.DeliveryID, attempt.ResponseStatus, attempt.Error, attempt.DurationMS).Scan(&attempt.ID, &attempt.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		var webhookID int64
+		query = `UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1,
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() END, updated_at = NOW()
+		WHERE id = $1 RETURNING webhook_id`
+		if err := tx.QueryRowContext(ctx, query, attempt.DeliveryID, status).Scan(&webhookID); err != nil {
+			return err
+		}
+
+		query = `UPDATE webhooks SET
+			consecutive_failures = CASE WHEN $2 THEN 0 ELSE consecutive_failures + 1 END,
+			active = active AND ($2 OR consecutive_failures + 1 < $3),
+			disabled_at = CASE WHEN active AND NOT $2 AND consecutive_failures + 1 >= $3 THEN NOW() ELSE disabled_at END,
+			updated_at = NOW()
+		WHERE id = $1 RETURNING active`
+		if err := tx.QueryRowContext(ctx, query, webhookID, succeeded, disableAfter).Scan(&active); err != nil {
+			return err
+		}
+		if active {
+			return nil
+		}
+		query = `UPDATE webhook_deliveries SET status = 'failed', updated_at = NOW() WHERE webhook_id = $1 AND status = 'pending'`
+		_, err = tx.ExecContext(ctx, query, webhookID)
+		return err
+	})
+	return active, err
+}
+
+// Redeliver queues a delivery of one of userID's webhooks to be sent again
+// with a fresh set of attempts. It returns ErrNotFound when there is no
+// such delivery, ErrWebhookDisabled when the webhook is disabled and
+// ErrDeliveryPending while the delivery is still pending.
+func (s *WebhookStore) Redeliver(ctx context.Context, userID, webhookID, deliveryID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var active bool
+		var status string
+		query := `SELECT w.active, d.status
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1 AND d.webhook_id = $2 AND w.user_id = $3
+		FOR UPDATE OF d`
+		if err := tx.QueryRowContext(ctx, query, deliveryID, webhookID, userID).Scan(&active, &status); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		switch {
+		case !active:
+			return ErrWebhookDisabled
+		case status == WebhookDeliveryPending:
+			return ErrDeliveryPending
+		}
+
+		query = `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, delivered_at = NULL, updated_at = NOW() WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, query, deliveryID); err != nil {
+			return err
+		}
+		return enqueueDelivery(ctx, tx, deliveryID)
+	})
+}
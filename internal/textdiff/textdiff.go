@@ -0,0 +1,94 @@
+// Package textdiff computes word-level differences between two texts.
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Op is one run of text that is unchanged, inserted into or deleted from
+// the old text.
+type Op struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Diff returns the operations that turn a into b. Text is compared word by
+// word, with runs of whitespace treated as words of their own, and adjacent
+// operations of the same type are merged.
+func Diff(a, b string) []Op {
+	at, bt := tokenize(a), tokenize(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of at[i:]
+	// and bt[j:].
+	lcs := make([][]int, len(at)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bt)+1)
+	}
+	for i := len(at) - 1; i >= 0; i-- {
+		for j := len(bt) - 1; j >= 0; j-- {
+			if at[i] == bt[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []Op{}
+	add := func(typ, text string) {
+		if n := len(ops); n > 0 && ops[n-1].Type == typ {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, Op{Type: typ, Text: text})
+	}
+	i, j := 0, 0
+	for i < len(at) && j < len(bt) {
+		switch {
+		case at[i] == bt[j]:
+			add(OpEqual, at[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(OpDelete, at[i])
+			i++
+		default:
+			add(OpInsert, bt[j])
+			j++
+		}
+	}
+	for ; i < len(at); i++ {
+		add(OpDelete, at[i])
+	}
+	for ; j < len(bt); j++ {
+		add(OpInsert, bt[j])
+	}
+	return ops
+}
+
+// tokenize splits s into alternating runs of whitespace and non-whitespace.
+func tokenize(s string) []string {
+	tokens := []string{}
+	var b strings.Builder
+	space := false
+	for i, r := range s {
+		isSpace := unicode.IsSpace(r)
+		if i > 0 && isSpace != space {
+			tokens = append(tokens, b.String())
+			b.Reset()
+		}
+		space = isSpace
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		tokens = append(tokens, b.String())
+	}
+	return tokens
+}
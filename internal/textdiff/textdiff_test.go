@@ -0,0 +1,85 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+// apply rebuilds the old and new texts from ops.
+func apply(ops []Op) (a, b string) {
+	for _, op := range ops {
+		switch op.Type {
+		case OpEqual:
+			a += op.Text
+			b += op.Text
+		case OpDelete:
+			a += op.Text
+		case OpInsert:
+			b += op.Text
+		}
+	}
+	return a, b
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{"both empty", "", "", []Op{}},
+		{"unchanged", "hello world", "hello world", []Op{{OpEqual, "hello world"}}},
+		{"from empty", "", "hello world", []Op{{OpInsert, "hello world"}}},
+		{"to empty", "hello world", "", []Op{{OpDelete, "hello world"}}},
+		{"insert only", "hello world", "hello there world", []Op{
+			{OpEqual, "hello "}, {OpInsert, "there "}, {OpEqual, "world"},
+		}},
+		{"delete only", "a b c", "a c", []Op{
+			{OpEqual, "a "}, {OpDelete, "b "}, {OpEqual, "c"},
+		}},
+		{"replace", "the cat sat", "the dog sat", []Op{
+			{OpEqual, "the "}, {OpDelete, "cat"}, {OpInsert, "dog"}, {OpEqual, " sat"},
+		}},
+		{"whitespace only", "a b", "a  b", []Op{
+			{OpEqual, "a"}, {OpDelete, " "}, {OpInsert, "  "}, {OpEqual, "b"},
+		}},
+		{"multi-byte", "héllo wörld", "héllo 世界", []Op{
+			{OpEqual, "héllo "}, {OpDelete, "wörld"}, {OpInsert, "世界"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRebuildsBothTexts(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"", "new text"},
+		{"old text", ""},
+		{"one two three four", "zero one three five four"},
+		{"  leading and trailing  ", "leading\tand\ntrailing"},
+		{"line one\nline two\n", "line one\nline 2\nline three\n"},
+		{"日本語　テキスト", "日本語 テキスト　追加"},
+		{"emoji 👍🏽 here", "emoji 👎 there"},
+		{"a a a a", "a b a"},
+	}
+	for _, tt := range tests {
+		ops := Diff(tt.a, tt.b)
+		if a, b := apply(ops); a != tt.a || b != tt.b {
+			t.Errorf("Diff(%q, %q) rebuilds %q and %q", tt.a, tt.b, a, b)
+		}
+		for i, op := range ops {
+			if op.Text == "" {
+				t.Errorf("Diff(%q, %q): op %d is empty", tt.a, tt.b, i)
+			}
+			if i > 0 && ops[i-1].Type == op.Type {
+				t.Errorf("Diff(%q, %q): ops %d and %d are both %s and were not merged", tt.a, tt.b, i-1, i, op.Type)
+			}
+		}
+	}
+}
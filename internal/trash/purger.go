@@ -0,0 +1,60 @@
+// Package trash permanently removes deleted posts once their retention
+// period is over.
+package trash
+
+import (
+	"context"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/blob"
+	"github.com/Chandan185/Societal/internal/jobs"
+	"github.com/Chandan185/Societal/internal/store"
+	"go.uber.org/zap"
+)
+
+// purgeBatchSize caps how many posts one purge transaction removes.
+const purgeBatchSize = 100
+
+// Purger deletes posts that have been in the trash for longer than the
+// retention period, together with their media files.
+type Purger struct {
+	store     store.Storage
+	blobs     blob.BlobStore
+	logger    *zap.SugaredLogger
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(store store.Storage, blobs blob.BlobStore, logger *zap.SugaredLogger, retention, interval time.Duration) *Purger {
+	return &Purger{
+		store:     store,
+		blobs:     blobs,
+		logger:    logger,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges expired posts every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	jobs.RunPeriodic(ctx, p.interval, p.purge)
+}
+
+// purge removes a batch of expired posts and reports whether more may have
+// expired.
+func (p *Purger) purge(ctx context.Context) bool {
+	n, keys, err := p.store.Posts.PurgeDeleted(ctx, time.Now().Add(-p.retention), purgeBatchSize)
+	if err != nil {
+		p.logger.Errorw("failed to purge deleted posts", "error", err)
+		return false
+	}
+	for _, key := range keys {
+		if err := p.blobs.Delete(ctx, key); err != nil {
+			p.logger.Warnw("failed to remove purged media", "key", key, "error", err)
+		}
+	}
+	if n > 0 {
+		p.logger.Infow("purged deleted posts", "posts", n, "files", len(keys))
+	}
+	return n == purgeBatchSize
+}
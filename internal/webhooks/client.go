@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs that point at the
+// server's own network rather than the public internet.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// NewClient returns the HTTP client deliveries are sent with. Users choose
+// webhook URLs, so it refuses to connect to loopback, private, link-local,
+// unspecified and multicast addresses. The check runs on the address
+// actually dialled, after DNS resolution, so a name that resolves, or is
+// rebound, to an internal address is refused too. Redirects are not
+// followed: a redirect response counts as a failed attempt.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addr.Addr()) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// A proxy would be dialled instead of the receiver, so the
+			// address check would not cover the receiver.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CheckHost rejects webhook hosts that are known to be internal without
+// resolving them: localhost and non-public IP literals. Names are only
+// checked when a delivery dials them.
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !publicAddr(addr) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
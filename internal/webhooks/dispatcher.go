@@ -0,0 +1,186 @@
+// Package webhooks delivers domain events to the HTTP endpoints users
+// subscribe. Events from the outbox are turned into deliveries, which are
+// sent by background jobs, signed, retried with backoff and recorded
+// attempt by attempt.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/events"
+	"github.com/Chandan185/Societal/internal/jobs"
+	"github.com/Chandan185/Societal/internal/store"
+	"go.uber.org/zap"
+)
+
+// maxResponseBody caps how much of a receiver's response is read, so the
+// connection can be reused. Only the status code is recorded.
+const maxResponseBody = 1 << 10
+
+// Envelope is the JSON body of a delivery.
+type Envelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type Dispatcher struct {
+	store  store.Storage
+	client *http.Client
+	logger *zap.SugaredLogger
+	pool   *jobs.Pool
+	// disableAfter is how many failed attempts in a row disable a webhook.
+	disableAfter int
+}
+
+// NewDispatcher returns a dispatcher that sends deliveries with client and
+// disables webhooks after disableAfter failed attempts in a row.
+func NewDispatcher(store store.Storage, client *http.Client, logger *zap.SugaredLogger, disableAfter int) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		client:       client,
+		logger:       logger,
+		disableAfter: disableAfter,
+	}
+}
+
+// Register subscribes d to the events webhooks can receive and has pool
+// run its deliveries.
+func (d *Dispatcher) Register(relay *events.Relay, pool *jobs.Pool) {
+	relay.Subscribe("webhooks", d.HandleEvent,
+		store.EventPostCreated, store.EventPostPublished, store.EventPostUpdated, store.EventPostDeleted,
+		store.EventPostRestored, store.EventPostPurged, store.EventCommentCreated,
+		store.EventUserFollowed, store.EventUserUnfollowed)
+	jobs.Handle(pool, store.WebhookDeliveryJob, d.Deliver)
+	d.pool = pool
+}
+
+// HandleEvent queues deliveries of an outbox event to the webhooks of the
+// users it concerns: the author of a post, the commenter and the post
+// author of a comment, and both sides of a follow.
+func (d *Dispatcher) HandleEvent(ctx context.Context, e events.Event) error {
+	userIDs, err := concernedUsers(e)
+	if err != nil {
+		d.logger.Warnw("skipping undecodable event", "id", e.ID, "type", e.Type, "error", err)
+		return nil
+	}
+	body, err := json.Marshal(Envelope{
+		ID:        e.ID,
+		Type:      e.Type,
+		CreatedAt: e.CreatedAt,
+		Data:      e.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	n, err := d.store.Webhooks.CreateDeliveries(ctx, e.ID, e.Type, userIDs, body)
+	if err != nil {
+		return err
+	}
+	if n > 0 && d.pool != nil {
+		d.pool.Notify()
+	}
+	return nil
+}
+
+func concernedUsers(e events.Event) ([]int64, error) {
+	switch e.Type {
+	case store.EventCommentCreated:
+		var c store.CommentEvent
+		if err := e.Decode(&c); err != nil {
+			return nil, err
+		}
+		return []int64{c.UserID, c.PostAuthorID}, nil
+	case store.EventUserFollowed, store.EventUserUnfollowed:
+		var f store.FollowEvent
+		if err := e.Decode(&f); err != nil {
+			return nil, err
+		}
+		return []int64{f.FollowerID, f.UserID}, nil
+	default:
+		var p store.PostEvent
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		return []int64{p.UserID}, nil
+	}
+}
+
+// Deliver makes one attempt at sending a delivery. It returns an error for
+// the job queue to retry the delivery until it succeeds, runs out of
+// attempts or its webhook is disabled.
+func (d *Dispatcher) Deliver(ctx context.Context, args store.WebhookDeliveryArgs) error {
+	delivery, webhook, err := d.store.Webhooks.GetDelivery(ctx, args.DeliveryID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// The webhook was deleted.
+			return nil
+		}
+		return err
+	}
+	if delivery.Status != store.WebhookDeliveryPending || !webhook.Active {
+		return nil
+	}
+
+	attempt := d.send(ctx, webhook, delivery)
+	final := delivery.Attempts+1 >= store.MaxWebhookAttempts
+	active, err := d.store.Webhooks.RecordAttempt(ctx, attempt, final, d.disableAfter)
+	if err != nil {
+		return err
+	}
+	switch {
+	case attempt.Succeeded():
+		return nil
+	case !active:
+		d.logger.Warnw("webhook disabled after repeated failures", "webhook", webhook.ID, "url", webhook.URL)
+		return nil
+	case final:
+		return nil
+	case attempt.Error != nil:
+		return errors.New(*attempt.Error)
+	default:
+		return fmt.Errorf("webhook %d responded with status %d", webhook.ID, *attempt.ResponseStatus)
+	}
+}
+
+// send posts the delivery to the webhook's URL and describes the outcome.
+func (d *Dispatcher) send(ctx context.Context, webhook *store.Webhook, delivery *store.WebhookDelivery) *store.WebhookAttempt {
+	attempt := &store.WebhookAttempt{DeliveryID: delivery.ID}
+	fail := func(err error) *store.WebhookAttempt {
+		msg := err.Error()
+		attempt.Error = &msg
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fail(err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Societal-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	start := time.Now()
+	res, err := d.client.Do(req)
+	attempt.DurationMS = int(time.Since(start).Milliseconds())
+	if err != nil {
+		return fail(err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+	attempt.ResponseStatus = &res.StatusCode
+	return attempt
+}
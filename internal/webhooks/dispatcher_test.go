@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Chandan185/Societal/internal/store"
+	"go.uber.org/zap"
+)
+
+// fakeWebhooks serves one webhook and delivery and records the attempts
+// made at it.
+type fakeWebhooks struct {
+	webhook  store.Webhook
+	delivery store.WebhookDelivery
+	attempts []store.WebhookAttempt
+}
+
+var errNotImplemented = errors.New("not implemented")
+
+func (f *fakeWebhooks) GetDelivery(ctx context.Context, deliveryID int64) (*store.WebhookDelivery, *store.Webhook, error) {
+	if deliveryID != f.delivery.ID {
+		return nil, nil, store.ErrNotFound
+	}
+	delivery, webhook := f.delivery, f.webhook
+	return &delivery, &webhook, nil
+}
+
+func (f *fakeWebhooks) RecordAttempt(ctx context.Context, attempt *store.WebhookAttempt, final bool, disableAfter int) (bool, error) {
+	f.attempts = append(f.attempts, *attempt)
+	f.delivery.Attempts++
+	if attempt.Succeeded() {
+		f.delivery.Status = store.WebhookDeliverySucceeded
+	}
+	return true, nil
+}
+
+func (f *fakeWebhooks) Create(context.Context, *store.Webhook) error { return errNotImplemented }
+func (f *fakeWebhooks) GetByUserID(context.Context, int64) ([]store.Webhook, error) {
+	return nil, errNotImplemented
+}
+func (f *fakeWebhooks) GetByID(context.Context, int64, int64) (*store.Webhook, error) {
+	return nil, errNotImplemented
+}
+func (f *fakeWebhooks) Update(context.Context, *store.Webhook) error { return errNotImplemented }
+func (f *fakeWebhooks) Delete(context.Context, int64, int64) error   { return errNotImplemented }
+func (f *fakeWebhooks) CreateDeliveries(context.Context, int64, string, []int64, json.RawMessage) (int, error) {
+	return 0, errNotImplemented
+}
+func (f *fakeWebhooks) GetDeliveries(context.Context, int64, int64, store.WebhookDeliveryQuery) ([]store.WebhookDelivery, error) {
+	return nil, errNotImplemented
+}
+func (f *fakeWebhooks) Redeliver(context.Context, int64, int64, int64) error {
+	return errNotImplemented
+}
+
+func newTestDispatcher(t *testing.T, url string, client *http.Client) (*Dispatcher, *fakeWebhooks) {
+	t.Helper()
+	fake := &fakeWebhooks{
+		webhook: store.Webhook{ID: 1, UserID: 1, URL: url, Secret: "a-secret-of-sixteen-bytes", Active: true},
+		delivery: store.WebhookDelivery{
+			ID:        7,
+			WebhookID: 1,
+			EventType: store.EventPostCreated,
+			Payload:   json.RawMessage(`{"id":1,"type":"post.created"}`),
+			Status:    store.WebhookDeliveryPending,
+		},
+	}
+	d := NewDispatcher(store.Storage{Webhooks: fake}, client, zap.NewNop().Sugar(), 5)
+	return d, fake
+}
+
+func TestDeliverSendsSignedPayload(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Clone(), body}
+		io.WriteString(w, "thanks, internal-host-42 handled it")
+	}))
+	defer srv.Close()
+
+	d, fake := newTestDispatcher(t, srv.URL, srv.Client())
+	if err := d.Deliver(context.Background(), store.WebhookDeliveryArgs{DeliveryID: fake.delivery.ID}); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	r := <-got
+	if string(r.body) != string(fake.delivery.Payload) {
+		t.Errorf("body = %s, want %s", r.body, fake.delivery.Payload)
+	}
+	if r.header.Get(HeaderEvent) != store.EventPostCreated {
+		t.Errorf("%s = %q, want %q", HeaderEvent, r.header.Get(HeaderEvent), store.EventPostCreated)
+	}
+	if r.header.Get(HeaderDelivery) != strconv.FormatInt(fake.delivery.ID, 10) {
+		t.Errorf("%s = %q, want %d", HeaderDelivery, r.header.Get(HeaderDelivery), fake.delivery.ID)
+	}
+	err := Verify(fake.webhook.Secret, r.header.Get(HeaderTimestamp), r.header.Get(HeaderSignature), r.body, time.Minute)
+	if err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	if len(fake.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(fake.attempts))
+	}
+	attempt := fake.attempts[0]
+	if attempt.ResponseStatus == nil || *attempt.ResponseStatus != http.StatusOK {
+		t.Errorf("response status = %v, want 200", attempt.ResponseStatus)
+	}
+	if attempt.Error != nil {
+		t.Errorf("error = %q, want none", *attempt.Error)
+	}
+}
+
+func TestDeliverRetriesFailedAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	d, fake := newTestDispatcher(t, srv.URL, srv.Client())
+	if err := d.Deliver(context.Background(), store.WebhookDeliveryArgs{DeliveryID: fake.delivery.ID}); err == nil {
+		t.Fatal("Deliver succeeded, want an error so the job is retried")
+	}
+	if len(fake.attempts) != 1 || fake.attempts[0].ResponseStatus == nil || *fake.attempts[0].ResponseStatus != http.StatusServiceUnavailable {
+		t.Errorf("attempts = %+v, want one with status 503", fake.attempts)
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect was followed")
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	client := NewClient(5 * time.Second)
+	// The test servers listen on loopback, which the client refuses, so
+	// only the redirect policy is tested here.
+	client.Transport = srv.Client().Transport
+	d, fake := newTestDispatcher(t, srv.URL, client)
+	if err := d.Deliver(context.Background(), store.WebhookDeliveryArgs{DeliveryID: fake.delivery.ID}); err == nil {
+		t.Fatal("Deliver succeeded, want a redirect to count as a failure")
+	}
+	if len(fake.attempts) != 1 || fake.attempts[0].ResponseStatus == nil || *fake.attempts[0].ResponseStatus != http.StatusTemporaryRedirect {
+		t.Errorf("attempts = %+v, want one with status 307", fake.attempts)
+	}
+}
+
+func TestDeliverRefusesInternalAddresses(t *testing.T) {
+	var hit atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit.Store(true)
+	}))
+	defer srv.Close()
+
+	d, fake := newTestDispatcher(t, srv.URL, NewClient(5*time.Second))
+	if err := d.Deliver(context.Background(), store.WebhookDeliveryArgs{DeliveryID: fake.delivery.ID}); err == nil {
+		t.Fatal("Deliver succeeded, want the loopback address refused")
+	}
+	if hit.Load() {
+		t.Error("the delivery reached a loopback address")
+	}
+	if len(fake.attempts) != 1 || fake.attempts[0].Error == nil || fake.attempts[0].ResponseStatus != nil {
+		t.Fatalf("attempts = %+v, want one that failed to connect", fake.attempts)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"example.com", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"localhost", false},
+		{"api.localhost", false},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		err := CheckHost(tt.host)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("CheckHost(%q) = %v, want allowed %v", tt.host, err, tt.allowed)
+		}
+	}
+}
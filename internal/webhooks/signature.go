@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Societal-Event"
+	HeaderDelivery  = "X-Societal-Delivery"
+	HeaderTimestamp = "X-Societal-Timestamp"
+	HeaderSignature = "X-Societal-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is too old")
+)
+
+// Sign returns the signature header for body sent at timestamp, a Unix
+// time: the hex HMAC-SHA256 under secret of the timestamp, a dot and the
+// body. Covering the timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the timestamp and signature headers of a delivery the way a
+// receiver should, rejecting deliveries signed more than tolerance ago.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package webhooks
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "a-secret-of-sixteen-bytes"
+	body := []byte(`{"id":1}`)
+	now := time.Now().Unix()
+	signature := Sign(secret, now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid", secret, strconv.FormatInt(now, 10), signature, body, nil},
+		{"wrong secret", "another-secret-entirely", strconv.FormatInt(now, 10), signature, body, ErrInvalidSignature},
+		{"tampered body", secret, strconv.FormatInt(now, 10), signature, []byte(`{"id":2}`), ErrInvalidSignature},
+		{"other timestamp", secret, strconv.FormatInt(now+1, 10), signature, body, ErrInvalidSignature},
+		{"invalid timestamp", secret, "now", signature, body, ErrInvalidSignature},
+		{"missing prefix", secret, strconv.FormatInt(now, 10), signature[len(signaturePrefix):], body, ErrInvalidSignature},
+		{"stale", secret, strconv.FormatInt(now-3600, 10), Sign(secret, now-3600, body), body, ErrStaleTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignIsDeterministic(t *testing.T) {
+	a := Sign("secret", 1700000000, []byte("body"))
+	b := Sign("secret", 1700000000, []byte("body"))
+	if a != b {
+		t.Errorf("signatures differ: %s and %s", a, b)
+	}
+	if got := Sign("secret", 1700000001, []byte("body")); got == a {
+		t.Error("signature does not cover the timestamp")
+	}
+}